@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/mohkale/dotty/pkg"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -69,6 +70,8 @@ func initLogger(opts *Options) {
 			os.Exit(1)
 		}
 	}
+	// secrets are decrypted at runtime, make sure they never make it into the logs.
+	writer = pkg.NewRedactingWriter(writer)
 
 	if opts.LogJson {
 		log.Logger = log.Output(writer)
@@ -56,9 +56,33 @@ func startDotty(opts *Options) *pkg.Context {
 		})
 	}
 
+	if envFiles := opts.EnvFiles.GetValues(); len(envFiles) > 0 {
+		// resolve paths from the command line against the cwd, not the root dir.
+		args := make(pkg.AnySlice, len(envFiles))
+		for i, file := range envFiles {
+			if abs, err := fp.Abs(file); err == nil {
+				file = abs
+			}
+			args[i] = file
+		}
+		pkg.ParseDirective(edn.Keyword("env-file"), ctx, args)
+	}
+
+	ctx.ActivateProfiles(opts.Profiles.GetValues())
+
+	// command line flags take precedence over the environment file.
+	if opts.Adopt {
+		pkg.ParseDirective(edn.Keyword("def"), ctx, pkg.AnySlice{
+			pkg.AnySlice{edn.Keyword("link"), "adopt", true}})
+	}
+	if opts.Interactive {
+		pkg.ParseDirective(edn.Keyword("def"), ctx, pkg.AnySlice{
+			pkg.AnySlice{edn.Keyword("link"), "interactive", true}})
+	}
+
 	go func() {
 		defer close(ctx.DirChan)
-		pkg.ParseDirective(edn.Keyword("import"), ctx, pkg.AnySlice{"config"})
+		pkg.ParseDirective(edn.Keyword("import"), ctx, ctx.ImportRoots())
 	}()
 
 	return ctx
@@ -77,15 +101,21 @@ func main() {
 	switch cmd {
 	case "install":
 		ctx := startDotty(opts)
-		for dir := range ctx.DirChan {
+		for _, dir := range pkg.PlanDirectives(ctx, opts.OverrideLinks) {
 			dir.Run()
 		}
 		if opts.SaveBots != "" {
 			saveBots(pkg.ExpandTilde(opts.HomeDir, pkg.JoinPath(opts.RootDir, opts.SaveBots)), ctx.Bots)
 		}
 	case "inspect":
-		for dir := range startDotty(opts).DirChan {
-			fmt.Println(dir.Log())
+		for _, dir := range pkg.PlanDirectives(startDotty(opts), opts.OverrideLinks) {
+			fmt.Println(pkg.RedactSecrets(dir.Log()))
+		}
+	case "diff":
+		for _, dir := range pkg.PlanDirectives(startDotty(opts), opts.OverrideLinks) {
+			if differ, ok := dir.(pkg.Differ); ok {
+				differ.Diff(pkg.NewRedactingWriter(os.Stdout))
+			}
 		}
 	case "list-dirs":
 		for key := range pkg.Directives {
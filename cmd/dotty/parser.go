@@ -34,12 +34,19 @@ type Options struct {
 	ExceptDirectives csvFlags
 	SaveBots         string
 	Bots             csvFlags
+	Profiles         csvFlags
+	EnvFiles         csvFlags
+	Adopt            bool
+	OverrideLinks    bool
+	Interactive      bool
 }
 
 func (opts *Options) init() *Options {
 	opts.LogLevel = loggingLevel(zerolog.InfoLevel)
 	opts.OnlyDirectives.metavar = "directive"
 	opts.ExceptDirectives.metavar = "directive"
+	opts.Profiles.metavar = "profile"
+	opts.EnvFiles.metavar = "path"
 	return opts
 }
 
@@ -67,12 +74,15 @@ func sharedConfigurationOpts(set *flag.FlagSet, opts *Options) {
 	set.StringVarP(&opts.RootDir, "cd", "d", cwd, "chdir to here before processing")
 	set.StringVarP(&opts.EnvConfig, "config", "c", "", "path to environment config. relative to rootdir.")
 	set.StringVarP(&opts.HomeDir, "home", "H", user.HomeDir, "path to environment config. relative to rootdir.")
+	set.VarP(&opts.Profiles, "profile", "p", "activate these machine profiles, instead of those matching the hostname.")
+	set.Var(&opts.EnvFiles, "env-file", "load environment variables from these dotenv files.")
 }
 
 func sharedInstallationOpts(set *flag.FlagSet, opts *Options) {
 	set.VarP(&opts.OnlyDirectives, "only", "o", "only run the supplied directives. this option overrides -e.")
 	set.VarP(&opts.ExceptDirectives, "except", "e", "run any directives apart from these")
 	set.VarP(&opts.Bots, "bots", "b", "specify subbots to invoke as csv. See README.")
+	set.BoolVar(&opts.OverrideLinks, "override-links", false, "when links conflict, let later definitions override earlier ones")
 }
 
 var subCommands = map[string]struct {
@@ -89,6 +99,8 @@ var subCommands = map[string]struct {
 				dottyBotsFile = envBots
 			}
 			set.StringVarP(&opts.SaveBots, "save-bots", "B", dottyBotsFile, "Append installing bots to this file. Set to empty to disable.")
+			set.BoolVar(&opts.Adopt, "adopt", false, "move existing files at link destinations into your dotfiles before linking")
+			set.BoolVarP(&opts.Interactive, "interactive", "i", false, "ask what to do when a link destination already exists")
 		}),
 	},
 	"inspect": {
@@ -98,6 +110,13 @@ var subCommands = map[string]struct {
 			sharedConfigurationOpts(set, opts)
 		}),
 	},
+	"diff": {
+		"show how installing would change existing files",
+		generateSubcommand("diff", func(set *flag.FlagSet, opts *Options) {
+			sharedInstallationOpts(set, opts)
+			sharedConfigurationOpts(set, opts)
+		}),
+	},
 	"list-dirs": {
 		"list all directives known to dotty",
 		generateSubcommand("list-dirs", func(set *flag.FlagSet, opts *Options) {
@@ -3,10 +3,15 @@ module github.com/mohkale/dotty
 go 1.15
 
 require (
+	filippo.io/age v1.0.0
+	github.com/BurntSushi/toml v0.3.1
 	github.com/drone/envsubst v1.0.2
 	github.com/gojp/goreportcard v0.0.0-20200415071653-59167b516f3f // indirect
 	github.com/google/go-cmp v0.4.0 // indirect
 	github.com/rs/zerolog v1.19.0
 	github.com/spf13/pflag v1.0.5
+	github.com/ulikunitz/xz v0.5.15
+	golang.org/x/term v0.0.0-20210615171337-6886f2dfbf5b
+	gopkg.in/yaml.v2 v2.4.0
 	olympos.io/encoding/edn v0.0.0-20200308123125-93e3b8dd0e24
 )
@@ -2,6 +2,8 @@ package pkg
 
 import (
 	"os"
+	fp "path/filepath"
+	"regexp"
 
 	"github.com/rs/zerolog/log"
 )
@@ -22,8 +24,23 @@ type Context struct {
 	// The path to all the configs we've imported.
 	imports *[]string
 
+	// The config file currently being read.
+	file string
+
 	Bots []string
 
+	// bots installed because of an active profile. these are kept apart from
+	// Bots, so they're not saved as if they were given on the command line.
+	profileBots []string
+
+	// the names of the active machine profiles.
+	Profiles []string
+
+	// profiles declared in the environment file, keyed by name, and whether
+	// they've already been activated.
+	declaredProfiles  map[string]*profile
+	profilesActivated bool
+
 	OnlyDirectives   []string
 	ExceptDirectives []string
 
@@ -31,12 +48,28 @@ type Context struct {
 	DirChan chan directive
 
 	// Key/Value options for specific directives or subshell environments.
-	mkdirOpts   map[string]Any
-	linkOpts    map[string]Any
-	cleanOpts   map[string]Any
-	shellOpts   map[string]Any
-	packageOpts map[string]Any
-	envOpts     map[string]string
+	mkdirOpts    map[string]Any
+	linkOpts     map[string]Any
+	cleanOpts    map[string]Any
+	shellOpts    map[string]Any
+	packageOpts  map[string]Any
+	chmodOpts    map[string]Any
+	syncOpts     map[string]Any
+	lineOpts     map[string]Any
+	blockOpts    map[string]Any
+	mergeOpts    map[string]Any
+	assembleOpts map[string]Any
+	fileOpts     map[string]Any
+	patchOpts    map[string]Any
+	gitOpts      map[string]Any
+	extractOpts  map[string]Any
+	downloadOpts map[string]Any
+	secretOpts   map[string]Any
+	envOpts      map[string]string
+
+	// list valued variables, such as PATH, whose values are also kept in
+	// envOpts joined with the OS path list separator.
+	envLists map[string][]string
 
 	// generated environment of the form that exec.Command can accept.
 	_env []string
@@ -55,11 +88,26 @@ func CreateContext() *Context {
 		mkdirOpts:        make(map[string]Any),
 		linkOpts:         make(map[string]Any),
 		cleanOpts:        make(map[string]Any),
+		Profiles:         make([]string, 0),
+		declaredProfiles: make(map[string]*profile),
 		OnlyDirectives:   make([]string, 0),
 		ExceptDirectives: make([]string, 0),
 		shellOpts:        make(map[string]Any),
 		packageOpts:      make(map[string]Any),
+		chmodOpts:        make(map[string]Any),
+		syncOpts:         make(map[string]Any),
+		lineOpts:         make(map[string]Any),
+		blockOpts:        make(map[string]Any),
+		mergeOpts:        make(map[string]Any),
+		assembleOpts:     make(map[string]Any),
+		fileOpts:         make(map[string]Any),
+		patchOpts:        make(map[string]Any),
+		gitOpts:          make(map[string]Any),
+		extractOpts:      make(map[string]Any),
+		downloadOpts:     make(map[string]Any),
+		secretOpts:       make(map[string]Any),
 		envOpts:          make(map[string]string),
+		envLists:         make(map[string][]string),
 		_env:             nil,
 	}
 }
@@ -83,6 +131,30 @@ func (ctx *Context) optsFromString(key string) (map[string]Any, bool) {
 		fallthrough
 	case key == "package":
 		return ctx.packageOpts, true
+	case key == "chmod":
+		return ctx.chmodOpts, true
+	case key == "sync":
+		return ctx.syncOpts, true
+	case key == "line-in-file":
+		return ctx.lineOpts, true
+	case key == "block-in-file":
+		return ctx.blockOpts, true
+	case key == "merge":
+		return ctx.mergeOpts, true
+	case key == "assemble":
+		return ctx.assembleOpts, true
+	case key == "file":
+		return ctx.fileOpts, true
+	case key == "patch":
+		return ctx.patchOpts, true
+	case key == "git":
+		return ctx.gitOpts, true
+	case key == "extract":
+		return ctx.extractOpts, true
+	case key == "download":
+		return ctx.downloadOpts, true
+	case key == "secret":
+		return ctx.secretOpts, true
 	}
 
 	return nil, false
@@ -107,10 +179,15 @@ func (ctx *Context) clone() *Context {
 	clone.Cwd = ctx.Cwd
 	clone.Shell = ctx.Shell
 	clone.Home = ctx.Home
+	clone.file = ctx.file
 
 	// fields that should be shared across all instances
 	// NOTE These aren't modifiable.
 	clone.Bots = ctx.Bots
+	clone.profileBots = ctx.profileBots
+	clone.Profiles = ctx.Profiles
+	clone.declaredProfiles = ctx.declaredProfiles
+	clone.profilesActivated = ctx.profilesActivated
 	clone.DirChan = ctx.DirChan
 	clone.OnlyDirectives = ctx.OnlyDirectives
 	clone.ExceptDirectives = ctx.ExceptDirectives
@@ -122,9 +199,25 @@ func (ctx *Context) clone() *Context {
 	_cloneDirectiveOpts(ctx.cleanOpts, clone.cleanOpts)
 	_cloneDirectiveOpts(ctx.shellOpts, clone.shellOpts)
 	_cloneDirectiveOpts(ctx.packageOpts, clone.packageOpts)
+	_cloneDirectiveOpts(ctx.chmodOpts, clone.chmodOpts)
+	_cloneDirectiveOpts(ctx.syncOpts, clone.syncOpts)
+	_cloneDirectiveOpts(ctx.lineOpts, clone.lineOpts)
+	_cloneDirectiveOpts(ctx.blockOpts, clone.blockOpts)
+	_cloneDirectiveOpts(ctx.mergeOpts, clone.mergeOpts)
+	_cloneDirectiveOpts(ctx.assembleOpts, clone.assembleOpts)
+	_cloneDirectiveOpts(ctx.fileOpts, clone.fileOpts)
+	_cloneDirectiveOpts(ctx.patchOpts, clone.patchOpts)
+	_cloneDirectiveOpts(ctx.gitOpts, clone.gitOpts)
+	_cloneDirectiveOpts(ctx.extractOpts, clone.extractOpts)
+	_cloneDirectiveOpts(ctx.downloadOpts, clone.downloadOpts)
+	_cloneDirectiveOpts(ctx.secretOpts, clone.secretOpts)
 	for key, value := range ctx.envOpts {
 		clone.envOpts[key] = value
 	}
+	// lists are never modified in place, so they can be shared.
+	for key, value := range ctx.envLists {
+		clone.envLists[key] = value
+	}
 
 	return clone
 }
@@ -138,27 +231,91 @@ func (ctx *Context) chdir(cwd string) *Context {
 	return c
 }
 
+// like os.LookupEnv but checking the context environment first.
+func (ctx *Context) lookupEnv(str string) (string, bool) {
+	if val, ok := ctx.envOpts[str]; ok {
+		return val, true
+	}
+	return os.LookupEnv(str)
+}
+
 /**
- * substitute variables from the current context environment
- * into str. Without first building an entire environment map.
+ * substitute variables from the current context environment into str,
+ * without first building an entire environment map. See expandVariables
+ * for the supported syntax. Undefined variables are logged as an error.
  */
-func (ctx *Context) getenv(str string) string {
-	if val, ok := ctx.envOpts[str]; ok {
-		return val
+func (ctx *Context) eval(str string) (string, bool) {
+	res, err := expandVariables(str, ctx.lookupEnv)
+	if err != nil {
+		log.Error().Str("str", str).
+			Str("error", err.Error()).
+			Msg("Failed to substitute variables")
+		return "", false
 	}
+	return res, true
+}
+
+// a reference to every element of a list variable, as in ${PATH[@]}.
+var listReferenceRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\[@\]\}`)
 
-	if val, ok := os.LookupEnv(str); ok {
-		return val
+/**
+ * like eval, except str is substituted once for each element of any list
+ * variable referenced as ${NAME[@]} in it. For example when DIRS is a list
+ * of foo and bar, "${DIRS[@]}/baz" evaluates to foo/baz and bar/baz.
+ */
+func (ctx *Context) evalPaths(str string) ([]string, bool) {
+	loc := listReferenceRegexp.FindStringSubmatchIndex(str)
+	if loc == nil {
+		res, ok := ctx.eval(str)
+		if !ok {
+			return nil, false
+		}
+		return []string{res}, true
 	}
 
-	log.Warn().Str("var", str).
-		Msg("Failed to find environment variable")
+	name := str[loc[2]:loc[3]]
+	elems, ok := ctx.lookupList(name)
+	if !ok {
+		log.Error().Str("str", str).
+			Str("error", "undefined variable: "+name).
+			Msg("Failed to substitute variables")
+		return nil, false
+	}
+	prefix, ok := ctx.eval(str[:loc[0]])
+	if !ok {
+		return nil, false
+	}
+	suffixes, ok := ctx.evalPaths(str[loc[1]:])
+	if !ok {
+		return nil, false
+	}
 
-	return ""
+	res := make([]string, 0, len(elems)*len(suffixes))
+	for _, elem := range elems {
+		for _, suffix := range suffixes {
+			res = append(res, prefix+elem+suffix)
+		}
+	}
+	return res, true
 }
 
-func (ctx *Context) eval(str string) (string, bool) {
-	return os.Expand(str, ctx.getenv), true
+// the elements of the list variable name. variables that weren't defined as
+// lists, such as PATH from the environment, are split on the path separator.
+func (ctx *Context) lookupList(name string) ([]string, bool) {
+	if list, ok := ctx.envLists[name]; ok {
+		return list, true
+	}
+	val, ok := ctx.lookupEnv(name)
+	if !ok {
+		return nil, false
+	}
+	var list []string
+	for _, elem := range fp.SplitList(val) {
+		if elem != "" {
+			list = append(list, elem)
+		}
+	}
+	return list, true
 }
 
 /**
@@ -192,7 +349,7 @@ func (ctx *Context) installingBot(bot string) bool {
 			return true
 		}
 	}
-	return false
+	return StringSliceContains(ctx.profileBots, bot)
 }
 
 func (ctx *Context) skipDirectivePredicate(dir string) bool {
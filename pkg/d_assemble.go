@@ -0,0 +1,251 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"olympos.io/encoding/edn"
+)
+
+/**
+ * A directive to build a file by concatenating fragments from your dotfiles,
+ * for programs that can't include other files themselves.
+ *
+ * Fragments can be globs, and can be maps with a :src and any of the usual
+ * conditions (:when, :if-bots) letting bot specific fragments be included
+ * only where they're needed. Every fragment found is sorted by its name
+ * (like the conf.d directories many programs support) and then joined
+ * together, between an optional header and footer.
+ */
+type assembleDirective struct {
+	dest string
+
+	// glob patterns for the fragments making up dest.
+	fragments []string
+
+	// lines written before and after the fragments.
+	header string
+	footer string
+
+	// when set, each fragment is preceded by a line beginning with comment
+	// noting where it came from.
+	comment string
+
+	// the root of the dotfiles, fragment origins are shown relative to it.
+	root string
+
+	// permissions of dest.
+	mode    os.FileMode
+	modeSet bool
+
+	// write dest as root.
+	sudo bool
+}
+
+func dAssemble(ctx *Context, args AnySlice) {
+	dSrcDestArgs(ctx, args, "assemble", func(opts map[Any]Any, srcArg, destArg Any) {
+		dests, ok := dLinkGeneratePaths(ctx.Cwd, ctx.evalPaths, destArg, "dest")
+		if !ok {
+			return
+		}
+		fragments, ok := dAssembleFragments(ctx, srcArg)
+		if !ok {
+			return
+		}
+		for _, dest := range dests {
+			dir := &assembleDirective{dest: ExpandTilde(ctx.Home, dest), fragments: fragments, root: ctx.Root}
+			if dir, ok := dir.init(ctx, opts); ok {
+				ctx.DirChan <- dir
+			}
+		}
+	})
+}
+
+// collect the fragment patterns from arg, which can be a path, a map with
+// a :src and conditions, or a list of either.
+func dAssembleFragments(ctx *Context, arg Any) ([]string, bool) {
+	switch frag := arg.(type) {
+	case AnySlice:
+		fragments := make([]string, 0, len(frag))
+		for _, item := range frag {
+			paths, ok := dAssembleFragments(ctx, item)
+			if !ok {
+				return nil, false
+			}
+			fragments = append(fragments, paths...)
+		}
+		return fragments, true
+	case map[Any]Any:
+		if !directiveMapCondition(ctx, frag) {
+			return []string{}, true
+		}
+		src, ok := frag[edn.Keyword("src")]
+		if !ok {
+			log.Error().Interface("spec", frag).
+				Msgf("%s fragment must specify a %s", edn.Keyword("assemble"), edn.Keyword("src"))
+			return nil, false
+		}
+		return dAssembleFragments(ctx, src)
+	case string:
+		if path, ok := ctx.eval(frag); ok {
+			return []string{ExpandTilde(ctx.Home, JoinPath(ctx.Cwd, fp.FromSlash(path)))}, true
+		}
+		return nil, false
+	}
+
+	log.Error().Interface("src", arg).
+		Msgf("%s fragments must be paths, maps or lists of them, not %T", edn.Keyword("assemble"), arg)
+	return nil, false
+}
+
+func (dir *assembleDirective) init(ctx *Context, opts map[Any]Any) (*assembleDirective, bool) {
+	_, dir.modeSet = ctx.assembleOpts["mode"]
+	if _, ok := opts[edn.Keyword("mode")]; ok {
+		dir.modeSet = true
+	}
+
+	ok := readMapOptionString(ctx.assembleOpts, opts, &dir.header, "header", "")
+	ok = readMapOptionString(ctx.assembleOpts, opts, &dir.footer, "footer", "") && ok
+	ok = readMapOptionString(ctx.assembleOpts, opts, &dir.comment, "comment", "") && ok
+	ok = readMapOptionMode(ctx.assembleOpts, opts, &dir.mode, "mode", editFileMode) && ok
+	ok = readMapOptionBool(ctx.assembleOpts, opts, &dir.sudo, "sudo", false) && ok
+	return dir, ok
+}
+
+func (dir *assembleDirective) Log() string {
+	var flags string
+	if dir.sudo {
+		flags += "sudo "
+	}
+	if dir.modeSet {
+		flags += fmt.Sprintf("--mode %s ", formatMode(dir.mode))
+	}
+	res := fmt.Sprintf("assemble %s%s", flags, dir.dest)
+	if fragments, err := dir.sources(); err == nil {
+		for _, fragment := range fragments {
+			res += "\n  " + fragment
+		}
+	}
+	return res
+}
+
+func (dir *assembleDirective) Run() {
+	before, after, exists, err := dir.plan()
+	if err != nil {
+		log.Error().Str("dest", dir.dest).
+			Str("error", err.Error()).
+			Msg("Failed to assemble file")
+		return
+	}
+
+	ops := fileOps{dir.sudo}
+	if before == after && exists {
+		log.Debug().Str("dest", dir.dest).
+			Msg("Skipping assemble because dest is up to date")
+	} else {
+		log.Info().Str("dest", dir.dest).
+			Msg("Assembling file")
+		if !exists {
+			if err := ops.mkdirAll(fp.Dir(dir.dest), 0744); err != nil {
+				log.Error().Str("dest", dir.dest).
+					Str("error", err.Error()).
+					Msg("Failed to create parent directory of assembled file")
+				return
+			}
+		}
+		if err := ops.writeFile(dir.dest, []byte(after), dir.mode); err != nil {
+			log.Error().Str("dest", dir.dest).
+				Str("error", err.Error()).
+				Msg("Failed to write assembled file")
+			return
+		}
+	}
+
+	if dir.modeSet {
+		reconcileMode(ops, dir.dest, dir.mode)
+	}
+}
+
+func (dir *assembleDirective) Diff(w io.Writer) {
+	if before, after, _, err := dir.plan(); err == nil {
+		fmt.Fprint(w, unifiedDiff(dir.dest, dir.dest, before, after))
+	}
+}
+
+// expand the fragment globs into the files they match, sorted by name.
+func (dir *assembleDirective) sources() ([]string, error) {
+	srcs := make([]string, 0, len(dir.fragments))
+	for _, fragment := range dir.fragments {
+		if !strings.ContainsAny(fragment, "*?[") {
+			srcs = append(srcs, fragment)
+			continue
+		}
+
+		matches, err := glob(fragment)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if isDir, _ := dirExists(match, true); !isDir {
+				srcs = append(srcs, match)
+			}
+		}
+	}
+
+	sort.SliceStable(srcs, func(i, j int) bool {
+		a, b := fp.Base(srcs[i]), fp.Base(srcs[j])
+		if a == b {
+			return srcs[i] < srcs[j]
+		}
+		return a < b
+	})
+	return srcs, nil
+}
+
+// work out the contents of dest before and after assembling it.
+func (dir *assembleDirective) plan() (string, string, bool, error) {
+	before, err := ioutil.ReadFile(dir.dest)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", false, err
+	}
+	exists := err == nil
+
+	srcs, err := dir.sources()
+	if err != nil {
+		return "", "", exists, err
+	}
+
+	var res strings.Builder
+	write := func(text string) {
+		res.WriteString(text)
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			res.WriteByte('\n')
+		}
+	}
+
+	write(dir.header)
+	for _, src := range srcs {
+		content, err := ioutil.ReadFile(src)
+		if err != nil {
+			return "", "", exists, err
+		}
+		if dir.comment != "" {
+			origin := src
+			if fileIsRelative(src, dir.root) {
+				if rel, err := fp.Rel(dir.root, src); err == nil {
+					origin = fp.ToSlash(rel)
+				}
+			}
+			write(fmt.Sprintf("%s fragment: %s", dir.comment, origin))
+		}
+		write(string(content))
+	}
+	write(dir.footer)
+	return string(before), res.String(), exists, nil
+}
@@ -0,0 +1,148 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"olympos.io/encoding/edn"
+)
+
+// the placeholder in a block marker replaced with BEGIN or END.
+const blockMarkPlaceholder = "{mark}"
+
+/**
+ * A directive to make sure a block of lines, surrounded by BEGIN and END
+ * markers, is present in (or absent from) a file.
+ *
+ * The markers let the block be found and replaced on later runs, so it
+ * always matches what's in your config. Files with more than one block
+ * should give each block its own marker.
+ */
+type blockInFileDirective struct {
+	// the file being edited.
+	path string
+
+	// the lines that should appear between the markers.
+	block []string
+
+	// the line surrounding the block, with {mark} replaced by BEGIN or END.
+	marker string
+
+	// remove the block from path, instead of adding it.
+	absent bool
+
+	// create path when it doesn't exist.
+	create bool
+
+	// edit path as root.
+	sudo bool
+}
+
+func dBlockInFile(ctx *Context, args AnySlice) {
+	dPathValuePairs(ctx, args, "path", "block", func(path string, opts map[Any]Any) (directive, bool) {
+		return (&blockInFileDirective{path: path}).init(ctx, opts)
+	})
+}
+
+func (dir *blockInFileDirective) init(ctx *Context, opts map[Any]Any) (*blockInFileDirective, bool) {
+	var block []string
+	ok := readMapOptionStrings(ctx.blockOpts, opts, &block, "block", nil)
+	ok = readMapOptionString(ctx.blockOpts, opts, &dir.marker, "marker", "# {mark} dotty") && ok
+	ok = readMapOptionState(ctx.blockOpts, opts, &dir.absent) && ok
+	ok = readMapOptionBool(ctx.blockOpts, opts, &dir.create, "create", true) && ok
+	ok = readMapOptionBool(ctx.blockOpts, opts, &dir.sudo, "sudo", false) && ok
+	if !ok {
+		return dir, false
+	}
+
+	if !strings.Contains(dir.marker, blockMarkPlaceholder) {
+		log.Error().Str("path", dir.path).
+			Str("marker", dir.marker).
+			Msgf("Block marker must contain %s", blockMarkPlaceholder)
+		return dir, false
+	}
+	if block == nil && !dir.absent {
+		log.Error().Str("path", dir.path).
+			Msgf("%s directive must specify a %s", edn.Keyword("block-in-file"), edn.Keyword("block"))
+		return dir, false
+	}
+
+	// a multiline string is treated the same as a list of lines.
+	dir.block = make([]string, 0, len(block))
+	for _, lines := range block {
+		dir.block = append(dir.block, strings.Split(strings.TrimSuffix(lines, "\n"), "\n")...)
+	}
+	return dir, true
+}
+
+func (dir *blockInFileDirective) markers() (string, string) {
+	return strings.Replace(dir.marker, blockMarkPlaceholder, "BEGIN", 1),
+		strings.Replace(dir.marker, blockMarkPlaceholder, "END", 1)
+}
+
+func (dir *blockInFileDirective) Log() string {
+	var flags string
+	if dir.sudo {
+		flags += "sudo "
+	}
+	if dir.absent {
+		flags += "--absent "
+	}
+	begin, _ := dir.markers()
+	return fmt.Sprintf("block-in-file %s%s %q", flags, dir.path, begin)
+}
+
+func (dir *blockInFileDirective) Run() {
+	changed, err := editFile(fileOps{dir.sudo}, dir.path, dir.create, dir.edit)
+	if err != nil {
+		log.Error().Str("path", dir.path).
+			Str("marker", dir.marker).
+			Str("error", err.Error()).
+			Msg("Failed to edit block in file")
+	} else if changed {
+		log.Info().Str("path", dir.path).
+			Str("marker", dir.marker).
+			Bool("absent", dir.absent).
+			Msg("Edited block in file")
+	} else {
+		log.Debug().Str("path", dir.path).
+			Str("marker", dir.marker).
+			Msg("Skipping block in file because it's already up to date")
+	}
+}
+
+func (dir *blockInFileDirective) Diff(w io.Writer) {
+	diffEditFile(w, dir.path, dir.edit)
+}
+
+func (dir *blockInFileDirective) edit(lines []string) ([]string, error) {
+	begin, end := dir.markers()
+	start, stop := -1, -1
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if start == -1 && line == begin {
+			start = i
+		} else if start != -1 && line == end {
+			stop = i
+			break
+		}
+	}
+
+	var block []string
+	if !dir.absent {
+		block = append(append([]string{begin}, dir.block...), end)
+	}
+
+	if start == -1 {
+		return append(lines, block...), nil
+	} else if stop == -1 {
+		return nil, fmt.Errorf("found %q with no matching %q", begin, end)
+	}
+
+	res := make([]string, 0, len(lines)+len(block))
+	res = append(res, lines[:start]...)
+	res = append(res, block...)
+	return append(res, lines[stop+1:]...), nil
+}
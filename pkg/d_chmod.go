@@ -0,0 +1,204 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"os"
+	fp "path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"olympos.io/encoding/edn"
+)
+
+/**
+ * A directive for making sure existing files or directories have the
+ * correct permissions and owner.
+ *
+ * Directories are given dirMode so they can still be traversed when the
+ * files in them shouldn't be executable. By default this is mode with
+ * anyone who can read a directory also allowed to search it.
+ */
+type chmodDirective struct {
+	// The path to the file or directory whose permissions are changed
+	path string
+
+	// The permissions for path (and any files below it when recursive).
+	mode    os.FileMode
+	modeSet bool
+
+	// The permissions for directories.
+	dirMode os.FileMode
+
+	// The user and group that should own path, as given and as ids. The ids
+	// are -1 when they should be left alone.
+	owner string
+	group string
+	uid   int
+	gid   int
+
+	// change the permissions of everything in path as well.
+	recursive bool
+
+	// change the permissions as root.
+	sudo bool
+}
+
+func dChmod(ctx *Context, args AnySlice) {
+	recursiveBuildDirectivesFromPaths(ctx, args,
+		func(ctx *Context, path string) {
+			if dir, ok := (&chmodDirective{path: ExpandTilde(ctx.Home, path)}).init(ctx); ok {
+				ctx.DirChan <- dir
+			}
+		},
+		func(opts map[Any]Any) (Any, bool) {
+			src, ok := opts[edn.Keyword("path")]
+			return src, ok
+		},
+		func(ctx *Context, opts map[Any]Any) (*Context, bool) {
+			if !directiveMapCondition(ctx, opts) {
+				return ctx, false
+			}
+
+			for _, opt := range []string{"mode", "dir-mode", "owner", "group", "recursive", "sudo"} {
+				if val, ok := opts[edn.Keyword(opt)]; ok {
+					ctx.chmodOpts[opt] = val
+				}
+			}
+			return ctx, true
+		},
+	)
+}
+
+func (dir *chmodDirective) init(ctx *Context) (*chmodDirective, bool) {
+	_, dir.modeSet = ctx.chmodOpts["mode"]
+	ok := readMapOptionMode(ctx.chmodOpts, nil, &dir.mode, "mode", 0)
+	ok = readMapOptionMode(ctx.chmodOpts, nil, &dir.dirMode, "dir-mode", traversableMode(dir.mode)) && ok
+	ok = readMapOptionString(ctx.chmodOpts, nil, &dir.owner, "owner", "") && ok
+	ok = readMapOptionString(ctx.chmodOpts, nil, &dir.group, "group", "") && ok
+	ok = readMapOptionBool(ctx.chmodOpts, nil, &dir.recursive, "recursive", false) && ok
+	ok = readMapOptionBool(ctx.chmodOpts, nil, &dir.sudo, "sudo", false) && ok
+	if !ok {
+		return dir, false
+	}
+
+	if !dir.modeSet && dir.owner == "" && dir.group == "" {
+		log.Error().Str("path", dir.path).
+			Msgf("%s directive must specify a %s, %s or %s", edn.Keyword("chmod"),
+				edn.Keyword("mode"), edn.Keyword("owner"), edn.Keyword("group"))
+		return dir, false
+	}
+
+	var err error
+	if dir.uid, dir.gid, err = lookupOwner(dir.owner, dir.group); err != nil {
+		log.Error().Str("path", dir.path).
+			Str("error", err.Error()).
+			Msg("Failed to find owner for path")
+		return dir, false
+	}
+	return dir, true
+}
+
+// mode with the execute bit set wherever the read bit is, so a directory
+// with mode can be listed and searched by the same users.
+func traversableMode(mode os.FileMode) os.FileMode {
+	return mode | (mode&0444)>>2
+}
+
+func (dir *chmodDirective) Log() string {
+	var flags string
+	if dir.recursive {
+		flags += "-R "
+	}
+	if dir.sudo {
+		flags = "sudo " + flags
+	}
+	if dir.owner != "" {
+		flags += fmt.Sprintf("--owner %s ", dir.owner)
+	}
+	if dir.group != "" {
+		flags += fmt.Sprintf("--group %s ", dir.group)
+	}
+	if dir.modeSet {
+		if dir.dirMode != dir.mode {
+			flags += fmt.Sprintf("--dir-mode %s ", formatMode(dir.dirMode))
+		}
+		flags += formatMode(dir.mode) + " "
+	}
+	return fmt.Sprintf("chmod %s%s", flags, dir.path)
+}
+
+func (dir *chmodDirective) Run() {
+	ops := fileOps{dir.sudo}
+	dir.walk(func(path string, info os.FileInfo, mode os.FileMode) {
+		// changing the owner can clear setuid and setgid bits, so it goes first.
+		if dir.uid >= 0 || dir.gid >= 0 {
+			reconcileOwner(ops, path, dir.uid, dir.gid)
+		}
+		if dir.modeSet {
+			reconcileMode(ops, path, mode)
+		}
+	})
+}
+
+// report any files whose permissions or owner have drifted from what they
+// should be.
+func (dir *chmodDirective) Diff(w io.Writer) {
+	dir.walk(func(path string, info os.FileInfo, mode os.FileMode) {
+		if uid, gid, ok := fileOwner(info); ok && (dir.uid >= 0 || dir.gid >= 0) {
+			wantUID, wantGID := uid, gid
+			if dir.uid >= 0 {
+				wantUID = dir.uid
+			}
+			if dir.gid >= 0 {
+				wantGID = dir.gid
+			}
+			if uid != wantUID || gid != wantGID {
+				fmt.Fprintf(w, "owner %s => %s %s\n", formatOwner(uid, gid), formatOwner(wantUID, wantGID), path)
+			}
+		}
+		if current := info.Mode() & chmodBits; dir.modeSet && current != mode {
+			fmt.Fprintf(w, "mode %s => %s %s\n", formatMode(current), formatMode(mode), path)
+		}
+	})
+}
+
+// call do with every path this directive should change the permissions of,
+// alongside the permissions that path should have.
+func (dir *chmodDirective) walk(do func(path string, info os.FileInfo, mode os.FileMode)) {
+	visit := func(path string, info os.FileInfo) {
+		if info.IsDir() {
+			do(path, info, dir.dirMode)
+		} else {
+			do(path, info, dir.mode)
+		}
+	}
+
+	info, err := os.Stat(dir.path)
+	if err != nil {
+		log.Error().Str("path", dir.path).
+			Str("error", err.Error()).
+			Msg("Failed to stat path to change permissions")
+		return
+	}
+
+	if !dir.recursive || !info.IsDir() {
+		visit(dir.path, info)
+		return
+	}
+
+	err = fp.Walk(dir.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// we don't follow symlinks below path, so there's nothing to change.
+		if info.Mode()&os.ModeSymlink == 0 {
+			visit(path, info)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error().Str("path", dir.path).
+			Str("error", err.Error()).
+			Msg("Error while recursing path")
+	}
+}
@@ -0,0 +1,50 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"testing"
+)
+
+func TestChmod_DirectoriesCanBeTraversedByDefault(t *testing.T) {
+	testCases := []struct {
+		mode, dirMode     Any
+		wantMode, wantDir os.FileMode
+	}{
+		{"600", nil, 0600, 0700},
+		{"640", nil, 0640, 0750},
+		{"644", "755", 0644, 0755},
+	}
+
+	for _, test := range testCases {
+		root := fp.Join(t.TempDir(), "root")
+		if err := os.MkdirAll(fp.Join(root, "sub"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fp.Join(root, "sub", "foo"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := CreateContext()
+		ctx.chmodOpts["mode"] = test.mode
+		ctx.chmodOpts["recursive"] = true
+		if test.dirMode != nil {
+			ctx.chmodOpts["dir-mode"] = test.dirMode
+		}
+		dir, ok := (&chmodDirective{path: root}).init(ctx)
+		if !ok {
+			t.Fatalf("expected chmod with mode %v to be valid", test.mode)
+		}
+		dir.Run()
+		for path, want := range map[string]os.FileMode{
+			root:                        test.wantDir,
+			fp.Join(root, "sub"):        test.wantDir,
+			fp.Join(root, "sub", "foo"): test.wantMode,
+		} {
+			if info, err := os.Stat(path); err != nil || info.Mode().Perm() != want {
+				t.Errorf("expected %s to have mode %s with mode %v, got %v (%v)", path, formatMode(want), test.mode, info, err)
+			}
+		}
+	}
+}
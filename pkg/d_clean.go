@@ -34,6 +34,9 @@ type cleanDirective struct {
 
 	// look in path and all valid subdirectories of path
 	recursive bool
+
+	// remove dead links as root
+	sudo bool
 }
 
 /**
@@ -57,7 +60,7 @@ func dClean(ctx *Context, args AnySlice) {
 			}
 
 			// luckily all configurable fields are booleans so no reflection needed.
-			for _, opt := range []string{"force", "recursive"} {
+			for _, opt := range []string{"force", "recursive", "sudo"} {
 				if arg, ok := opts[edn.Keyword(opt)]; ok {
 					if argBool, ok := arg.(bool); ok {
 						ctx.cleanOpts[opt] = argBool
@@ -77,6 +80,7 @@ func dClean(ctx *Context, args AnySlice) {
 func (dir *cleanDirective) init(ctx *Context) *cleanDirective {
 	readMapOptionBool(ctx.cleanOpts, nil, &dir.force, "force", false)
 	readMapOptionBool(ctx.cleanOpts, nil, &dir.recursive, "recursive", false)
+	readMapOptionBool(ctx.cleanOpts, nil, &dir.sudo, "sudo", false)
 	return dir
 }
 
@@ -88,6 +92,9 @@ func (dir *cleanDirective) Log() string {
 	if dir.recursive {
 		flags += "-r "
 	}
+	if dir.sudo {
+		return fmt.Sprintf("sudo clean %s%s", flags, dir.path)
+	}
 	return fmt.Sprintf("clean %s%s", flags, dir.path)
 }
 
@@ -137,7 +144,7 @@ func (dir *cleanDirective) Run() {
 				Msg("Error when checking file exists")
 		} else if !exists {
 			log.Info().Str("path", file.path).Msg("Cleaning dead link")
-			if err := os.Remove(file.path); err != nil {
+			if err := (fileOps{dir.sudo}).remove(file.path); err != nil {
 				log.Error().Str("path", file.path).
 					Str("error", err.Error()).
 					Msg("Error when removing dead link")
@@ -2,6 +2,9 @@ package pkg
 
 import (
 	"fmt"
+	"os"
+	fp "path/filepath"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 	"olympos.io/encoding/edn"
@@ -32,11 +35,25 @@ import (
 // Pseudo directive for assigning options in the current context.
 func dDef(ctx *Context, args AnySlice) {
 	var assignEnvOpt = func(key string, val Any) {
-		valString, _ := ctx.eval(fmt.Sprintf("%s", val))
+		if vals, ok := val.(AnySlice); ok && len(vals) > 0 && vals[0] == edn.Keyword("secret") {
+			dDefSecret(ctx, key, vals[1:])
+			return
+		} else if ok {
+			if list, ok := dDefListValues(ctx, vals); ok {
+				ctx.setEnvList(key, uniqueStrings(list, false))
+			}
+			return
+		}
+
+		valString, ok := ctx.eval(fmt.Sprintf("%s", val))
+		if !ok {
+			return
+		}
 		log.Debug().Str("key", key).
 			Str("val", valString).
 			Msg("Setting environment key with value")
 		ctx.envOpts[key] = valString
+		delete(ctx.envLists, key)
 		ctx.invalidateEnv()
 	}
 
@@ -66,6 +83,16 @@ func dDef(ctx *Context, args AnySlice) {
 
 			if dest == edn.Keyword("env") {
 				dDefDirectiveOpts(ctx, args[1:], assignEnvOpt, keyTypeError)
+			} else if dest == edn.Keyword("profile") {
+				dDefProfile(ctx, args[1:])
+			} else if dest == edn.Keyword("prepend") || dest == edn.Keyword("append") {
+				dDefExtendList(ctx, args[1:], dest)
+			} else if dest == edn.Keyword("env-file") {
+				// the environment file is read as arguments to :def, where this
+				// would look like it loads a dotenv file without doing so.
+				log.Error().Interface("args", args[1:]).
+					Msgf("%s can't be used in %s or your environment file, use the %s directive or --env-file instead",
+						dest, edn.Keyword("def"), dest)
 			} else if destMap, ok := ctx.optsFromString(string(dest)); ok {
 				dDefDirectiveOpts(ctx, args[1:], func(key string, value Any) {
 					log.Debug().Str("key", key).
@@ -107,3 +134,128 @@ func dDefDirectiveOpts(ctx *Context, args AnySlice, callback func(string, Any),
 		}
 	}
 }
+
+// extend a list variable with values, in the form:
+//
+//	(:prepend "PATH" "~/.local/bin" "~/bin")
+//
+// when the variable isn't already a list its current value is split on the
+// path separator. values already in the list are moved rather than repeated.
+func dDefExtendList(ctx *Context, args AnySlice, dest edn.Keyword) {
+	if len(args) < 2 {
+		log.Error().Interface("args", args).
+			Msgf("%s must be given a variable name and at least one value", dest)
+		return
+	}
+	key, ok := args[0].(string)
+	if !ok {
+		log.Error().Interface("key", args[0]).
+			Msgf("%s variable name must be a string, not %T", dest, args[0])
+		return
+	}
+	vals, ok := dDefListValues(ctx, args[1:])
+	if !ok {
+		return
+	}
+
+	list, _ := ctx.lookupList(key)
+	if dest == edn.Keyword("prepend") {
+		list = uniqueStrings(append(vals, list...), false)
+	} else {
+		list = uniqueStrings(append(append([]string{}, list...), vals...), true)
+	}
+	ctx.setEnvList(key, list)
+}
+
+// evaluate the values of a list variable, expanding any leading tildes.
+func dDefListValues(ctx *Context, args AnySlice) ([]string, bool) {
+	vals := make([]string, 0, len(args))
+	for _, arg := range args {
+		str, ok := arg.(string)
+		if !ok {
+			log.Error().Interface("value", arg).
+				Msgf("%s list values must be strings, not %T", edn.Keyword("def"), arg)
+			return nil, false
+		}
+		if str, ok = ctx.eval(str); !ok {
+			return nil, false
+		}
+		if strings.HasPrefix(str, "~") {
+			str = ExpandTilde(ctx.Home, fp.FromSlash(str))
+		}
+		if str != "" {
+			vals = append(vals, str)
+		}
+	}
+	return vals, true
+}
+
+func (ctx *Context) setEnvList(key string, list []string) {
+	log.Debug().Str("key", key).
+		Strs("val", list).
+		Msg("Setting environment key with list value")
+	ctx.envLists[key] = list
+	ctx.envOpts[key] = strings.Join(list, string(os.PathListSeparator))
+	ctx.invalidateEnv()
+}
+
+// remove any repeated strings from list, keeping either the first or the
+// last occurrence of each.
+func uniqueStrings(list []string, keepLast bool) []string {
+	seen := make(map[string]bool, len(list))
+	res := make([]string, 0, len(list))
+	if keepLast {
+		for i := len(list) - 1; i >= 0; i-- {
+			if !seen[list[i]] {
+				seen[list[i]] = true
+				res = append(res, list[i])
+			}
+		}
+		for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+			res[i], res[j] = res[j], res[i]
+		}
+		return res
+	}
+	for _, str := range list {
+		if !seen[str] {
+			seen[str] = true
+			res = append(res, str)
+		}
+	}
+	return res
+}
+
+// assign key to the decrypted contents of an encrypted file, in the form:
+//
+//	(:def "GITHUB_TOKEN" (:secret "secrets/github-token.age"))
+//
+// a single trailing newline is dropped from the secret.
+func dDefSecret(ctx *Context, key string, args AnySlice) {
+	var path string
+	if len(args) == 1 {
+		path, _ = args[0].(string)
+	}
+	if path == "" {
+		log.Error().Str("key", key).
+			Msgf("%s must be given the path to a single encrypted file", edn.Keyword("secret"))
+		return
+	}
+	file, ok := ctx.secretPath(path)
+	if !ok {
+		return
+	}
+	secret, err := ctx.decryptSecret(file)
+	if err != nil {
+		log.Error().Str("key", key).
+			Str("path", file).
+			Str("error", err.Error()).
+			Msg("Failed to decrypt secret")
+		return
+	}
+
+	log.Debug().Str("key", key).
+		Msg("Setting environment key with secret value")
+	ctx.envOpts[key] = strings.TrimSuffix(strings.TrimSuffix(secret, "\n"), "\r")
+	delete(ctx.envLists, key)
+	ctx.invalidateEnv()
+}
@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testListContext() *Context {
+	ctx := CreateContext()
+	ctx.Home = "/home/foo"
+	ctx.envOpts["TEST_PATH"] = strings.Join([]string{"/usr/bin", "/bin"}, string(os.PathListSeparator))
+	return ctx
+}
+
+func TestDef_PrependAndAppendDeduplicate(t *testing.T) {
+	ctx := testListContext()
+	dDef(ctx, pathsFromEdn(`((:prepend "TEST_PATH" "~/.local/bin" "/bin")
+	                         (:append "TEST_PATH" "/usr/bin" "/opt/bin"))`))
+
+	expected := []string{"/home/foo/.local/bin", "/bin", "/usr/bin", "/opt/bin"}
+	if list, _ := ctx.lookupList("TEST_PATH"); !reflect.DeepEqual(list, expected) {
+		t.Errorf("expected TEST_PATH to be %v, got %v", expected, list)
+	}
+	if val := ctx.envOpts["TEST_PATH"]; val != strings.Join(expected, string(os.PathListSeparator)) {
+		t.Errorf("expected TEST_PATH to be joined in the environment, got %q", val)
+	}
+}
+
+func TestDef_ListValuesAreReplacedByStrings(t *testing.T) {
+	ctx := testListContext()
+	dDef(ctx, pathsFromEdn(`("DIRS" ("foo" "bar" "foo"))`))
+	if list := ctx.envLists["DIRS"]; !reflect.DeepEqual(list, []string{"foo", "bar"}) {
+		t.Errorf("expected DIRS to be a list of foo and bar, got %v", list)
+	}
+
+	dDef(ctx, pathsFromEdn(`("DIRS" "baz")`))
+	if _, ok := ctx.envLists["DIRS"]; ok {
+		t.Error("expected DIRS to no longer be a list")
+	}
+	if val := ctx.envOpts["DIRS"]; val != "baz" {
+		t.Errorf("expected DIRS to be baz, got %q", val)
+	}
+}
+
+func TestEvalPaths_IteratesOverLists(t *testing.T) {
+	ctx := testListContext()
+	dDef(ctx, pathsFromEdn(`("DIRS" ("foo" "bar") "EXTS" ("a" "b"))`))
+
+	paths, ok := ctx.evalPaths("${DIRS[@]}/file.${EXTS[@]}")
+	expected := []string{"foo/file.a", "foo/file.b", "bar/file.a", "bar/file.b"}
+	if !ok || !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected paths %v, got %v", expected, paths)
+	}
+
+	if paths, ok = ctx.evalPaths("${TEST_PATH[@]}/ls"); !ok ||
+		!reflect.DeepEqual(paths, []string{"/usr/bin/ls", "/bin/ls"}) {
+		t.Errorf("expected variables that aren't lists to be split, got %v", paths)
+	}
+	if _, ok = ctx.evalPaths("${UNDEFINED_LIST[@]}/foo"); ok {
+		t.Error("expected undefined list to fail")
+	}
+}
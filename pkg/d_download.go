@@ -0,0 +1,349 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	fp "path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"olympos.io/encoding/edn"
+)
+
+// how long to wait before the first retry of a failed download, each retry
+// after that waits a little longer.
+var downloadRetryDelay = 2 * time.Second
+
+// how long an attempt at a download can go without receiving anything before
+// it's abandoned. the next retry resumes from wherever the attempt got to.
+var downloadTimeout = 30 * time.Second
+
+/**
+ * A directive to download a file (such as a single binary release) from url
+ * to dest.
+ *
+ * When sha256 is given the download is verified against it, cached by its
+ * checksum and only fetched again when dest no longer matches. Without it
+ * dest is downloaded once and then left alone. Interrupted downloads are
+ * resumed from where they stopped, retrying up to retries times.
+ */
+type downloadDirective struct {
+	url  string
+	dest string
+
+	// the expected hex encoded sha256 checksum of the download.
+	sha256 string
+
+	// permissions of dest, applied even when it already exists if modeSet.
+	mode    os.FileMode
+	modeSet bool
+
+	// how many more times to try the download after it fails.
+	retries int
+
+	// where complete and partial downloads are kept.
+	cacheDir string
+
+	// the client to download with.
+	client *http.Client
+}
+
+func dDownload(ctx *Context, args AnySlice) {
+	dRemoteDestPairs(ctx, args, "download", "url", func(opts map[Any]Any, url, dest string) {
+		if dir, ok := (&downloadDirective{url: url, dest: dest}).init(ctx, opts); ok {
+			ctx.DirChan <- dir
+		}
+	})
+}
+
+// the directory downloads are cached in, following the XDG base directory spec.
+func downloadCacheDir(ctx *Context) string {
+	cache, ok := ctx.lookupEnv("XDG_CACHE_HOME")
+	if !ok || cache == "" {
+		cache = JoinPath(ctx.Home, ".cache")
+	}
+	return JoinPath(cache, "dotty", "downloads")
+}
+
+// an HTTP client for downloads, which is sent through any proxies set in the
+// environment of ctx. this includes those set with :def or an env file, not
+// just the environment dotty was run with.
+func downloadClient(ctx *Context) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyConfigFromEnv(ctx.lookupEnv).proxy
+	return &http.Client{Transport: transport}
+}
+
+func (dir *downloadDirective) init(ctx *Context, opts map[Any]Any) (*downloadDirective, bool) {
+	var requireSha256 bool
+	ok := readMapOptionString(ctx.downloadOpts, opts, &dir.sha256, "sha256", "")
+	ok = readMapOptionBool(ctx.downloadOpts, opts, &requireSha256, "require-sha256", false) && ok
+	ok = readMapOptionInt(ctx.downloadOpts, opts, &dir.retries, "retries", 3) && ok
+	ok = readMapOptionMode(ctx.downloadOpts, opts, &dir.mode, "mode", 0) && ok
+	dir.modeSet = dir.mode != 0
+	if !dir.modeSet {
+		dir.mode = editFileMode
+	}
+	if !ok {
+		return dir, false
+	}
+	dir.cacheDir = downloadCacheDir(ctx)
+	dir.client = downloadClient(ctx)
+
+	dir.sha256 = strings.ToLower(dir.sha256)
+	if dir.sha256 == "" {
+		if requireSha256 {
+			log.Error().Str("url", dir.url).
+				Msgf("%s directive must specify a %s", edn.Keyword("download"), edn.Keyword("sha256"))
+			return dir, false
+		}
+	} else if _, err := hex.DecodeString(dir.sha256); err != nil || len(dir.sha256) != 2*sha256.Size {
+		log.Error().Str("url", dir.url).
+			Str("sha256", dir.sha256).
+			Msg("sha256 should be a hex encoded checksum")
+		return dir, false
+	}
+	if dir.retries < 0 {
+		log.Error().Int("retries", dir.retries).
+			Msg("retries can't be negative")
+		return dir, false
+	}
+	return dir, true
+}
+
+func (dir *downloadDirective) Log() string {
+	var flags string
+	if dir.sha256 != "" {
+		flags += fmt.Sprintf("--sha256 %s ", dir.sha256)
+	}
+	if dir.modeSet {
+		flags += fmt.Sprintf("--mode %s ", formatMode(dir.mode))
+	}
+	return fmt.Sprintf("download %s%s %s", flags, dir.url, dir.dest)
+}
+
+// the path the download is cached at. downloads without a checksum can't be
+// reused, so they're only kept until they've been copied to dest.
+func (dir *downloadDirective) cachePath() string {
+	if dir.sha256 != "" {
+		return JoinPath(dir.cacheDir, dir.sha256)
+	}
+	hash := sha256.Sum256([]byte(dir.url))
+	return JoinPath(dir.cacheDir, "url-"+hex.EncodeToString(hash[:]))
+}
+
+// assert whether path exists and, when we have a checksum, matches it.
+func (dir *downloadDirective) upToDate(path string) bool {
+	if exists, _ := fileExists(path, true); !exists {
+		return false
+	}
+	if dir.sha256 == "" {
+		return true
+	}
+	checksum, err := sha256File(path)
+	return err == nil && checksum == dir.sha256
+}
+
+func (dir *downloadDirective) Run() {
+	if exists, _ := dirExists(dir.dest, true); exists {
+		log.Error().Str("dest", dir.dest).
+			Msg("Unable to download file because dest is a directory")
+		return
+	}
+
+	if dir.upToDate(dir.dest) {
+		log.Debug().Str("url", dir.url).
+			Str("dest", dir.dest).
+			Msg("Skipping download because dest is up to date")
+	} else if !dir.download() {
+		return
+	}
+
+	if dir.modeSet {
+		reconcileMode(fileOps{}, dir.dest, dir.mode)
+	}
+}
+
+// download url into the cache, when it isn't already there, and then copy it
+// to dest.
+func (dir *downloadDirective) download() bool {
+	fail := func(msg string, err error) bool {
+		log.Error().Str("url", dir.url).
+			Str("dest", dir.dest).
+			Str("error", err.Error()).
+			Msg(msg)
+		return false
+	}
+
+	cached := dir.cachePath()
+	if dir.sha256 != "" && dir.upToDate(cached) {
+		log.Info().Str("url", dir.url).
+			Str("dest", dir.dest).
+			Msg("Copying download from cache")
+	} else {
+		log.Info().Str("url", dir.url).
+			Str("dest", dir.dest).
+			Msg("Downloading file")
+		if err := os.MkdirAll(dir.cacheDir, 0744); err != nil {
+			return fail("Failed to create download cache", err)
+		}
+		if dir.sha256 == "" {
+			// without a checksum we can't tell whether an earlier partial download
+			// is of what url serves now, so start again.
+			if err := os.Remove(cached + ".part"); err != nil && !os.IsNotExist(err) {
+				return fail("Failed to remove partial download", err)
+			}
+		}
+		if err := downloadFile(dir.client, dir.url, cached+".part", dir.retries); err != nil {
+			return fail("Failed to download file", err)
+		}
+		if err := dir.verify(cached + ".part"); err != nil {
+			os.Remove(cached + ".part")
+			return fail("Failed to verify download", err)
+		}
+		if err := os.Rename(cached+".part", cached); err != nil {
+			return fail("Failed to cache download", err)
+		}
+	}
+	if dir.sha256 == "" {
+		defer os.Remove(cached)
+	}
+
+	if err := os.MkdirAll(fp.Dir(dir.dest), 0744); err != nil {
+		return fail("Failed to create parent directory of download", err)
+	}
+	// copy next to dest and then rename, so dest is never left half written.
+	tmp := dir.dest + ".dotty-tmp"
+	defer os.Remove(tmp)
+	if err := copyFile(cached, tmp); err != nil {
+		return fail("Failed to copy download to dest", err)
+	}
+	if err := os.Chmod(tmp, dir.mode); err != nil {
+		return fail("Failed to set permissions of download", err)
+	}
+	if err := os.Rename(tmp, dir.dest); err != nil {
+		return fail("Failed to copy download to dest", err)
+	}
+	return true
+}
+
+func (dir *downloadDirective) verify(path string) error {
+	if dir.sha256 == "" {
+		return nil
+	}
+	checksum, err := sha256File(path)
+	if err != nil {
+		return err
+	}
+	if checksum != dir.sha256 {
+		return fmt.Errorf("checksum mismatch, expected %s but got %s", dir.sha256, checksum)
+	}
+	return nil
+}
+
+/**
+ * Download url to path, resuming from the end of path when it already exists
+ * (such as when an earlier download was interrupted). Failed attempts are
+ * retried up to retries times, with an increasing delay between them, unless
+ * the server rejected the request outright.
+ */
+func downloadFile(client *http.Client, url, path string, retries int) error {
+	for attempt := 0; ; attempt++ {
+		retry, err := downloadFileOnce(client, url, path)
+		if err == nil || !retry || attempt >= retries {
+			return err
+		}
+
+		delay := time.Duration(attempt+1) * downloadRetryDelay
+		log.Warn().Str("url", url).
+			Str("error", err.Error()).
+			Str("delay", delay.String()).
+			Msg("Download failed, retrying")
+		time.Sleep(delay)
+	}
+}
+
+// a single attempt at downloadFile, returning whether it's worth retrying
+// when it fails.
+func downloadFileOnce(client *http.Client, url, path string) (bool, error) {
+	// abandon the attempt whenever it stops making progress, rather than
+	// after a fixed time which large downloads could never finish within.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	idle := time.AfterFunc(downloadTimeout, cancel)
+	defer idle.Stop()
+	timeoutErr := func(err error) error {
+		if ctx.Err() != nil {
+			return fmt.Errorf("nothing received for %s", downloadTimeout)
+		}
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	var offset int64
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() && info.Size() > 0 {
+		offset = info.Size()
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, timeoutErr(err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	switch {
+	case resp.StatusCode == http.StatusPartialContent &&
+		strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		// the server ignored our range, so start again from scratch.
+		flags |= os.O_TRUNC
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// there's nothing left to download.
+		return false, nil
+	default:
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		if resp.StatusCode == http.StatusPartialContent {
+			// the server resumed from the wrong place, so start again.
+			os.Remove(path)
+			retry = true
+		}
+		return retry, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	out, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(out, &idleReader{resp.Body, idle}); err != nil {
+		out.Close()
+		return true, timeoutErr(err)
+	}
+	return false, out.Close()
+}
+
+// a reader that pushes back an idle timer whenever anything is read.
+type idleReader struct {
+	r     io.Reader
+	timer *time.Timer
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.timer.Reset(downloadTimeout)
+	}
+	return n, err
+}
@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	fp "path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testDownloadContent = []byte(strings.Repeat("dotty downloads\n", 64))
+
+func testDownloadServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request) bool) (*httptest.Server, string) {
+	downloadRetryDelay = 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler == nil || handler(w, r) {
+			http.ServeContent(w, r, "tool", time.Time{}, bytes.NewReader(testDownloadContent))
+		}
+	}))
+	tmp, err := ioutil.TempDir("", "dotty-download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, tmp
+}
+
+func assertDownloaded(t *testing.T, path string) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read download: %s", err)
+	}
+	if !bytes.Equal(content, testDownloadContent) {
+		t.Errorf("download doesn't match served content, got %q", content)
+	}
+}
+
+func TestDownloadFile_ResumesPartialDownload(t *testing.T) {
+	var ranges []string
+	server, tmp := testDownloadServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		ranges = append(ranges, r.Header.Get("Range"))
+		return true
+	})
+	defer server.Close()
+	defer os.RemoveAll(tmp)
+
+	path := fp.Join(tmp, "tool.part")
+	ioutil.WriteFile(path, testDownloadContent[:100], 0644)
+	if err := downloadFile(server.Client(), server.URL, path, 0); err != nil {
+		t.Fatalf("failed to download: %s", err)
+	}
+	assertDownloaded(t, path)
+	if len(ranges) != 1 || ranges[0] != "bytes=100-" {
+		t.Errorf("expected download to resume from byte 100, got ranges %v", ranges)
+	}
+}
+
+func TestDownloadFile_RetriesServerErrors(t *testing.T) {
+	requests := 0
+	server, tmp := testDownloadServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		if requests++; requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return false
+		}
+		return true
+	})
+	defer server.Close()
+	defer os.RemoveAll(tmp)
+
+	path := fp.Join(tmp, "tool")
+	if err := downloadFile(server.Client(), server.URL, path, 1); err == nil {
+		t.Error("expected download to fail after running out of retries")
+	}
+	if err := downloadFile(server.Client(), server.URL, path, 1); err != nil {
+		t.Fatalf("failed to download after retrying: %s", err)
+	}
+	assertDownloaded(t, path)
+}
+
+func TestDownloadFile_DoesNotRetryClientErrors(t *testing.T) {
+	requests := 0
+	server, tmp := testDownloadServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		requests++
+		http.NotFound(w, r)
+		return false
+	})
+	defer server.Close()
+	defer os.RemoveAll(tmp)
+
+	if err := downloadFile(server.Client(), server.URL, fp.Join(tmp, "tool"), 3); err == nil {
+		t.Error("expected download of missing file to fail")
+	}
+	if requests != 1 {
+		t.Errorf("expected a single request, got %d", requests)
+	}
+}
+
+func TestDownloadFile_OnlyAbandonsDownloadsThatStall(t *testing.T) {
+	defer func(timeout time.Duration) { downloadTimeout = timeout }(downloadTimeout)
+	downloadTimeout = 100 * time.Millisecond
+
+	chunk := len(testDownloadContent) / 8
+	server, tmp := testDownloadServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		// slower overall than the timeout, but never idle for as long as it.
+		for i := 0; i < len(testDownloadContent); i += chunk {
+			w.Write(testDownloadContent[i : i+chunk])
+			w.(http.Flusher).Flush()
+			if r.URL.Path == "/stalled" {
+				time.Sleep(3 * downloadTimeout)
+				return false
+			}
+			time.Sleep(downloadTimeout / 4)
+		}
+		return false
+	})
+	defer server.Close()
+	defer os.RemoveAll(tmp)
+
+	if err := downloadFile(server.Client(), server.URL, fp.Join(tmp, "tool"), 0); err != nil {
+		t.Errorf("expected slow download to finish, got %s", err)
+	}
+	assertDownloaded(t, fp.Join(tmp, "tool"))
+
+	if err := downloadFile(server.Client(), server.URL+"/stalled", fp.Join(tmp, "stalled"), 0); err == nil {
+		t.Error("expected stalled download to be abandoned")
+	}
+}
+
+func TestDownloadDirective_RestartsPartialDownloadsWithoutChecksum(t *testing.T) {
+	server, tmp := testDownloadServer(t, nil)
+	defer server.Close()
+	defer os.RemoveAll(tmp)
+
+	dir := &downloadDirective{
+		url:      server.URL,
+		dest:     fp.Join(tmp, "tool"),
+		mode:     0644,
+		cacheDir: fp.Join(tmp, "cache"),
+		client:   server.Client(),
+	}
+	// left over from before the content at url changed.
+	os.MkdirAll(dir.cacheDir, 0755)
+	ioutil.WriteFile(dir.cachePath()+".part", []byte("stale"), 0644)
+	dir.Run()
+	assertDownloaded(t, dir.dest)
+}
+
+func TestDownloadDirective_VerifiesChecksum(t *testing.T) {
+	server, tmp := testDownloadServer(t, nil)
+	defer server.Close()
+	defer os.RemoveAll(tmp)
+
+	dir := &downloadDirective{
+		url:      server.URL,
+		dest:     fp.Join(tmp, "bin", "tool"),
+		sha256:   strings.Repeat("0", 64),
+		mode:     0755,
+		cacheDir: fp.Join(tmp, "cache"),
+		client:   server.Client(),
+	}
+	dir.Run()
+	if exists, _ := pathExists(dir.dest, false); exists {
+		t.Error("download with the wrong checksum was written to dest")
+	}
+
+	ioutil.WriteFile(fp.Join(tmp, "tool"), testDownloadContent, 0644)
+	dir.sha256, _ = sha256File(fp.Join(tmp, "tool"))
+	dir.Run()
+	assertDownloaded(t, dir.dest)
+	if info, err := os.Stat(dir.dest); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("expected dest to have mode 0755, got %v", info)
+	}
+	assertDownloaded(t, fp.Join(dir.cacheDir, dir.sha256))
+}
@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"olympos.io/encoding/edn"
+)
+
+/**
+ * Load environment variables from dotenv files, see parseDotenv for the
+ * supported syntax. Files are loaded in order, so later files can override
+ * or reference the variables of earlier ones. Missing files are an error
+ * unless the :optional option is set.
+ *   (:env-file ".env" {:path ".env.local" :optional true})
+ *
+ * Like :def, the variables apply to every directive after this one.
+ */
+func dEnvFile(ctx *Context, args AnySlice) {
+	for _, arg := range args {
+		paths, opts := arg, map[Any]Any(nil)
+		if argMap, ok := arg.(map[Any]Any); ok {
+			if !directiveMapCondition(ctx, argMap) {
+				continue
+			}
+			if paths, ok = argMap[edn.Keyword("path")]; !ok {
+				log.Error().Interface("opts", argMap).
+					Msgf("%s directive must be given a %s", edn.Keyword("env-file"), edn.Keyword("path"))
+				continue
+			}
+			opts = argMap
+		}
+
+		var optional bool
+		if !readMapOptionBool(nil, opts, &optional, "optional", false) {
+			continue
+		}
+		files, ok := dLinkGeneratePaths(ctx.Cwd, ctx.evalPaths, paths, "path")
+		if !ok {
+			continue
+		}
+		for _, file := range files {
+			ctx.loadEnvFile(ExpandTilde(ctx.Home, file), optional)
+		}
+	}
+}
+
+// assign every variable in the dotenv file at path in the context environment.
+func (ctx *Context) loadEnvFile(path string, optional bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			log.Debug().Str("path", path).
+				Msg("Skipping missing optional environment file")
+			return
+		}
+		log.Error().Str("path", path).
+			Str("error", err.Error()).
+			Msg("Failed to read environment file")
+		return
+	}
+
+	vars, err := parseDotenv(string(data), ctx.lookupEnv)
+	if err != nil {
+		log.Error().Str("path", path).
+			Str("error", err.Error()).
+			Msg("Failed to parse environment file")
+		return
+	}
+
+	log.Info().Str("path", path).
+		Int("count", len(vars)).
+		Msg("Loading environment file")
+	for _, v := range vars {
+		log.Debug().Str("key", v.key).
+			Msg("Setting environment key from file")
+		ctx.envOpts[v.key] = v.val
+		delete(ctx.envLists, v.key)
+	}
+	ctx.invalidateEnv()
+}
@@ -0,0 +1,208 @@
+package pkg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"olympos.io/encoding/edn"
+)
+
+// the file in an extracted archive recording the checksum of the archive.
+const extractStampName = ".dotty-extract"
+
+/**
+ * A directive to extract an archive from your dotfiles into dest, for tools
+ * you vendor as tarballs or zips.
+ *
+ * The checksum of the archive is recorded in dest, so it's only extracted
+ * again when the archive changes. When it is, dest is replaced entirely so
+ * no files from the old archive are left behind. bins are then linked into
+ * binDir so they can be found on your PATH.
+ */
+type extractDirective struct {
+	src  string
+	dest string
+
+	// the format of src, guessed from its extension.
+	format string
+
+	// how many leading components to remove from each path in the archive.
+	strip int
+
+	// paths relative to dest to link into binDir.
+	bins   []string
+	binDir string
+
+	// replace dest even when it wasn't extracted by dotty.
+	force bool
+}
+
+func dExtract(ctx *Context, args AnySlice) {
+	dSrcDestPairs(ctx, args, "extract", func(opts map[Any]Any, src, dest string) {
+		if dir, ok := (&extractDirective{src: src, dest: dest}).init(ctx, opts); ok {
+			ctx.DirChan <- dir
+		}
+	})
+}
+
+func (dir *extractDirective) init(ctx *Context, opts map[Any]Any) (*extractDirective, bool) {
+	ok := readMapOptionString(ctx.extractOpts, opts, &dir.format, "format", "")
+	ok = readMapOptionInt(ctx.extractOpts, opts, &dir.strip, "strip-components", 0) && ok
+	ok = readMapOptionStrings(ctx.extractOpts, opts, &dir.bins, "bin", nil) && ok
+	ok = readMapOptionString(ctx.extractOpts, opts, &dir.binDir, "bin-dir", "~/.local/bin") && ok
+	ok = readMapOptionBool(ctx.extractOpts, opts, &dir.force, "force", false) && ok
+	if !ok {
+		return dir, false
+	}
+
+	binDir, ok := ctx.eval(dir.binDir)
+	if !ok {
+		return dir, false
+	}
+	dir.binDir = ExpandTilde(ctx.Home, JoinPath(ctx.Cwd, fp.FromSlash(binDir)))
+	if dir.format == "" {
+		if dir.format, ok = archiveFormatFromPath(dir.src); !ok {
+			log.Error().Str("src", dir.src).
+				Msgf("Unable to guess format of archive, please specify a %s", edn.Keyword("format"))
+			return dir, false
+		}
+	}
+	if dir.strip < 0 {
+		log.Error().Int("strip-components", dir.strip).
+			Msg("strip-components can't be negative")
+		return dir, false
+	}
+	return dir, true
+}
+
+func (dir *extractDirective) Log() string {
+	var flags string
+	if dir.strip != 0 {
+		flags += fmt.Sprintf("--strip-components %d ", dir.strip)
+	}
+	if dir.force {
+		flags += "-f "
+	}
+	res := fmt.Sprintf("extract %s%s %s", flags, dir.src, dir.dest)
+	for _, bin := range dir.bins {
+		res += fmt.Sprintf("\n  ln -s %s %s", JoinPath(dir.dest, bin), dir.binDir)
+	}
+	return res
+}
+
+func (dir *extractDirective) Run() {
+	checksum, err := sha256File(dir.src)
+	if err != nil {
+		log.Error().Str("src", dir.src).
+			Str("error", err.Error()).
+			Msg("Failed to read archive")
+		return
+	}
+
+	stampPath := JoinPath(dir.dest, extractStampName)
+	stamp, err := ioutil.ReadFile(stampPath)
+	if err == nil && strings.TrimSpace(string(stamp)) == checksum {
+		log.Debug().Str("src", dir.src).
+			Str("dest", dir.dest).
+			Msg("Skipping extract because archive hasn't changed")
+	} else if !dir.extract(checksum, err == nil) {
+		return
+	}
+	dir.linkBins()
+}
+
+// extract the archive into a temporary directory and then swap it with dest.
+// stamped is whether dest was previously extracted by dotty.
+func (dir *extractDirective) extract(checksum string, stamped bool) bool {
+	if exists, _ := pathExists(dir.dest, false); exists && !stamped && !dir.force {
+		if entries, err := ioutil.ReadDir(dir.dest); err != nil || len(entries) != 0 {
+			log.Error().Str("dest", dir.dest).
+				Msgf("Unable to extract archive because dest already exists, use %s to replace it", edn.Keyword("force"))
+			return false
+		}
+	}
+
+	log.Info().Str("src", dir.src).
+		Str("dest", dir.dest).
+		Msg("Extracting archive")
+	fail := func(err error) bool {
+		log.Error().Str("src", dir.src).
+			Str("dest", dir.dest).
+			Str("error", err.Error()).
+			Msg("Failed to extract archive")
+		return false
+	}
+
+	tmp := dir.dest + ".dotty-tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return fail(err)
+	}
+	defer os.RemoveAll(tmp)
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return fail(err)
+	}
+	if err := extractArchive(dir.src, dir.format, tmp, dir.strip); err != nil {
+		return fail(err)
+	}
+	if err := ioutil.WriteFile(JoinPath(tmp, extractStampName), []byte(checksum+"\n"), 0644); err != nil {
+		return fail(err)
+	}
+
+	if err := os.RemoveAll(dir.dest); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmp, dir.dest); err != nil {
+		return fail(err)
+	}
+	return true
+}
+
+// link each of bins into binDir, replacing any existing links to them.
+func (dir *extractDirective) linkBins() {
+	if len(dir.bins) == 0 {
+		return
+	}
+	if err := os.MkdirAll(dir.binDir, 0744); err != nil {
+		log.Error().Str("dir", dir.binDir).
+			Str("error", err.Error()).
+			Msg("Failed to create bin directory")
+		return
+	}
+
+	for _, bin := range dir.bins {
+		src := JoinPath(dir.dest, fp.FromSlash(bin))
+		dest := JoinPath(dir.binDir, fp.Base(src))
+		if exists, _ := fileExists(src, true); !exists {
+			log.Error().Str("bin", bin).
+				Str("dest", dir.dest).
+				Msg("Unable to link bin because it isn't in the extracted archive")
+			continue
+		}
+
+		if target, err := os.Readlink(dest); err == nil {
+			if target == src {
+				continue
+			}
+			os.Remove(dest)
+		} else if exists, _ := pathExists(dest, false); exists {
+			log.Warn().Str("src", src).
+				Str("dest", dest).
+				Msg("Skipping bin because dest already exists and isn't a symlink")
+			continue
+		}
+
+		log.Info().Str("src", src).
+			Str("dest", dest).
+			Msg("Linking bin")
+		if err := os.Symlink(src, dest); err != nil {
+			log.Error().Str("src", src).
+				Str("dest", dest).
+				Str("error", err.Error()).
+				Msg("Failed to link bin")
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"olympos.io/encoding/edn"
+)
+
+/**
+ * A directive to write content from your config straight into a file,
+ * for small files that aren't worth keeping in your dotfiles.
+ *
+ * dest is only written when its contents differ from content, but any
+ * changes to dest are always overwritten. A symlink at dest (such as one
+ * left over from a :link) is only replaced when force is true, so we never
+ * write through it into your dotfiles.
+ *
+ * content can instead be decrypted from an encrypted file with :secret, in
+ * which case (or when content contains any other secret) dest is only
+ * readable by the current user by default and content is never output.
+ */
+type fileDirective struct {
+	dest string
+
+	// what should be written to dest.
+	content string
+
+	// permissions of dest, applied even when it already exists if modeSet.
+	mode    os.FileMode
+	modeSet bool
+
+	// create any missing parent directories of dest.
+	mkdirs bool
+
+	// replace dest when it's a symlink.
+	force bool
+
+	// write dest as root.
+	sudo bool
+
+	// content contains a secret.
+	secret bool
+}
+
+func dFile(ctx *Context, args AnySlice) {
+	dPathValuePairs(ctx, args, "dest", "content", func(dest string, opts map[Any]Any) (directive, bool) {
+		return (&fileDirective{dest: dest}).init(ctx, opts)
+	})
+}
+
+func (dir *fileDirective) init(ctx *Context, opts map[Any]Any) (*fileDirective, bool) {
+	secretPath, isSecret := opts[edn.Keyword("secret")]
+	var expand bool
+	ok := readMapOptionBool(ctx.fileOpts, opts, &expand, "expand", !isSecret)
+	ok = readMapOptionBool(ctx.fileOpts, opts, &dir.mkdirs, "mkdirs", true) && ok
+	ok = readMapOptionBool(ctx.fileOpts, opts, &dir.force, "force", false) && ok
+	ok = readMapOptionBool(ctx.fileOpts, opts, &dir.sudo, "sudo", false) && ok
+	ok = readMapOptionMode(ctx.fileOpts, opts, &dir.mode, "mode", 0) && ok
+	dir.modeSet = dir.mode != 0
+	if !dir.modeSet {
+		dir.mode = editFileMode
+	}
+	if !ok {
+		return dir, false
+	}
+
+	content, hasContent := opts[edn.Keyword("content")]
+	if isSecret {
+		if hasContent {
+			log.Error().Str("dest", dir.dest).
+				Msgf("%s directive can't specify both %s and %s", edn.Keyword("file"), edn.Keyword("content"), edn.Keyword("secret"))
+			return dir, false
+		}
+		if content, ok = dir.readSecret(ctx, secretPath); !ok {
+			return dir, false
+		}
+	} else if !hasContent {
+		log.Error().Str("dest", dir.dest).
+			Msgf("%s directive must specify some %s", edn.Keyword("file"), edn.Keyword("content"))
+		return dir, false
+	}
+
+	// a string is written as is, each line in a list ends with a newline.
+	var lines []string
+	if str, isStr := content.(string); isStr {
+		lines = []string{str}
+	} else if !readMapOptionStrings(nil, opts, &lines, "content", nil) {
+		return dir, false
+	} else {
+		lines = append(lines, "")
+	}
+
+	if expand {
+		for i, line := range lines {
+			if lines[i], ok = ctx.eval(line); !ok {
+				return dir, false
+			}
+		}
+	}
+	dir.content = strings.Join(lines, "\n")
+
+	// never leave secrets readable by other users, unless asked to.
+	dir.secret = isSecret || containsSecret(dir.content)
+	if dir.secret && !dir.modeSet {
+		dir.mode, dir.modeSet = secretFileMode, true
+	}
+	return dir, true
+}
+
+// the permissions of files containing secrets.
+const secretFileMode os.FileMode = 0600
+
+// decrypt the file at path for the :secret option.
+func (dir *fileDirective) readSecret(ctx *Context, path Any) (string, bool) {
+	pathStr, ok := path.(string)
+	if !ok {
+		log.Error().Str("dest", dir.dest).
+			Msgf("%s must be the path to an encrypted file, not %T", edn.Keyword("secret"), path)
+		return "", false
+	}
+	if pathStr, ok = ctx.secretPath(pathStr); !ok {
+		return "", false
+	}
+	secret, err := ctx.decryptSecret(pathStr)
+	if err != nil {
+		log.Error().Str("dest", dir.dest).
+			Str("path", pathStr).
+			Str("error", err.Error()).
+			Msg("Failed to decrypt secret")
+		return "", false
+	}
+	return secret, true
+}
+
+func (dir *fileDirective) Log() string {
+	var flags string
+	if dir.sudo {
+		flags += "sudo "
+	}
+	if dir.force {
+		flags += "-f "
+	}
+	if dir.modeSet {
+		flags += fmt.Sprintf("--mode %s ", formatMode(dir.mode))
+	}
+	if dir.secret {
+		return fmt.Sprintf("file %s%s %s", flags, dir.dest, redactedSecret)
+	}
+	return fmt.Sprintf("file %s%s %q", flags, dir.dest, dir.content)
+}
+
+func (dir *fileDirective) Run() {
+	ops := fileOps{dir.sudo}
+	info, err := os.Lstat(dir.dest)
+	if err != nil && !os.IsNotExist(err) {
+		log.Error().Str("dest", dir.dest).
+			Str("error", err.Error()).
+			Msg("Failed to stat file dest")
+		return
+	}
+
+	if info != nil && info.IsDir() {
+		log.Error().Str("dest", dir.dest).
+			Msg("Unable to write file because dest is a directory")
+		return
+	} else if info != nil && info.Mode()&os.ModeSymlink != 0 {
+		if !dir.force {
+			log.Warn().Str("dest", dir.dest).
+				Msgf("Skipping file because dest is a symlink, use %s to replace it", edn.Keyword("force"))
+			return
+		}
+		log.Info().Str("dest", dir.dest).
+			Msg("Removing existing symlink")
+		if err := ops.remove(dir.dest); err != nil {
+			log.Error().Str("dest", dir.dest).
+				Str("error", err.Error()).
+				Msg("Failed to remove existing symlink")
+			return
+		}
+		info = nil
+	}
+
+	if info == nil {
+		parent := fp.Dir(dir.dest)
+		if exists, _ := dirExists(parent, true); !exists {
+			if !dir.mkdirs {
+				log.Error().Str("dest", dir.dest).
+					Msg("Unable to write file because its parent directory doesn't exist")
+				return
+			}
+			if err := ops.mkdirAll(parent, 0744); err != nil {
+				log.Error().Str("dest", dir.dest).
+					Str("error", err.Error()).
+					Msg("Failed to create parent directory of file")
+				return
+			}
+		}
+	}
+
+	if current, err := ioutil.ReadFile(dir.dest); info != nil && err == nil && string(current) == dir.content {
+		log.Debug().Str("dest", dir.dest).
+			Msg("Skipping file because dest is up to date")
+	} else {
+		log.Info().Str("dest", dir.dest).
+			Bool("exists", info != nil).
+			Msg("Writing file")
+		// writing keeps the permissions of an existing dest, so restrict them
+		// first or a secret could be briefly readable by other users.
+		if info != nil && dir.secret && info.Mode().Perm()&^dir.mode != 0 {
+			if err := ops.chmod(dir.dest, info.Mode().Perm()&dir.mode); err != nil {
+				log.Error().Str("dest", dir.dest).
+					Str("error", err.Error()).
+					Msg("Failed to restrict permissions of file before writing secret")
+				return
+			}
+		}
+		if err := ops.writeFile(dir.dest, []byte(dir.content), dir.mode); err != nil {
+			log.Error().Str("dest", dir.dest).
+				Str("error", err.Error()).
+				Msg("Failed to write file")
+			return
+		}
+	}
+
+	if dir.modeSet {
+		reconcileMode(ops, dir.dest, dir.mode)
+	}
+}
+
+func (dir *fileDirective) Diff(w io.Writer) {
+	current, err := ioutil.ReadFile(dir.dest)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+	if dir.secret {
+		if string(current) != dir.content {
+			fmt.Fprintf(w, "Secret file %s differs\n", dir.dest)
+		}
+		return
+	}
+	fmt.Fprint(w, unifiedDiff(dir.dest, dir.dest, string(current), dir.content))
+}
@@ -0,0 +1,272 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	fp "path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+/**
+ * A directive to clone a git repository (such as a plugin manager or theme)
+ * into dest and keep it checked out at ref.
+ *
+ * A repository that's already been cloned is only fetched from when update
+ * is true, in which case the checked out branch is fast forwarded to match
+ * its upstream. Any change to the checked out commit is reported.
+ */
+type gitDirective struct {
+	// the remote to clone, either a URL or a path on the local file system.
+	repo string
+
+	// where to clone the repository.
+	dest string
+
+	// the branch, tag or commit to checkout. when empty we use whatever the
+	// remote has checked out.
+	ref string
+
+	// fetch from the remote on each run.
+	update bool
+
+	// clone and update submodules alongside the repository.
+	submodules bool
+}
+
+func dGit(ctx *Context, args AnySlice) {
+	dRemoteDestPairs(ctx, args, "git", "repo", func(opts map[Any]Any, repo, dest string) {
+		if gitRemoteIsLocal(repo) {
+			repo = ExpandTilde(ctx.Home, JoinPath(ctx.Cwd, fp.FromSlash(repo)))
+		}
+		if dir, ok := (&gitDirective{repo: repo, dest: dest}).init(ctx, opts); ok {
+			ctx.DirChan <- dir
+		}
+	})
+}
+
+// assert whether repo is a path on the local file system, rather than a URL
+// or an scp like address (user@host:path).
+func gitRemoteIsLocal(repo string) bool {
+	if strings.Contains(repo, "://") {
+		return false
+	}
+	colon := strings.IndexByte(repo, ':')
+	if colon == -1 {
+		return true
+	}
+	// windows drive letters and paths with a slash before the colon.
+	return (colon == 1 && isWindows()) || strings.ContainsAny(repo[:colon], `/\`)
+}
+
+func (dir *gitDirective) init(ctx *Context, opts map[Any]Any) (*gitDirective, bool) {
+	ok := readMapOptionString(ctx.gitOpts, opts, &dir.ref, "ref", "")
+	ok = readMapOptionBool(ctx.gitOpts, opts, &dir.update, "update", false) && ok
+	ok = readMapOptionBool(ctx.gitOpts, opts, &dir.submodules, "submodules", true) && ok
+	return dir, ok
+}
+
+func (dir *gitDirective) Log() string {
+	var flags string
+	if dir.ref != "" {
+		flags += fmt.Sprintf("--ref %s ", dir.ref)
+	}
+	if dir.update {
+		flags += "--update "
+	}
+	if !dir.submodules {
+		flags += "--no-submodules "
+	}
+	return fmt.Sprintf("git %s%s %s", flags, dir.repo, dir.dest)
+}
+
+// run git with args in dest, returning its trimmed output.
+func (dir *gitDirective) git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", dir.dest}, args...)...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (dir *gitDirective) Run() {
+	if exists, _ := pathExists(dir.dest, true); !exists {
+		dir.clone()
+		return
+	}
+
+	if !dir.isRepository() {
+		log.Error().Str("dest", dir.dest).
+			Msg("Unable to update repository because dest isn't a git repository")
+		return
+	}
+	if origin, err := dir.git("remote", "get-url", "origin"); err == nil && origin != dir.repo {
+		log.Warn().Str("dest", dir.dest).
+			Str("repo", dir.repo).
+			Str("origin", origin).
+			Msg("Repository was cloned from a different remote")
+	}
+
+	before, err := dir.git("rev-parse", "HEAD")
+	if err == nil {
+		err = dir.sync()
+	}
+	if err != nil {
+		log.Error().Str("repo", dir.repo).
+			Str("dest", dir.dest).
+			Str("error", err.Error()).
+			Msg("Failed to update repository")
+		return
+	}
+
+	after, _ := dir.git("rev-parse", "HEAD")
+	if before == after {
+		log.Debug().Str("dest", dir.dest).
+			Msg("Skipping repository because it's already up to date")
+		return
+	}
+	log.Info().Str("dest", dir.dest).
+		Str("from", shortCommit(before)).
+		Str("to", shortCommit(after)).
+		Msg("Updated repository")
+	dir.updateSubmodules()
+}
+
+// assert whether dest is the root of a git repository. git commands run in
+// any directory inside of a repository (such as your home directory, when
+// it's tracked by git) apply to that repository, which we must never touch.
+func (dir *gitDirective) isRepository() bool {
+	if exists, _ := pathExists(fp.Join(dir.dest, ".git"), false); exists {
+		return true
+	}
+	top, err := dir.git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return false
+	}
+	topInfo, err := os.Stat(top)
+	if err != nil {
+		return false
+	}
+	destInfo, err := os.Stat(dir.dest)
+	return err == nil && os.SameFile(topInfo, destInfo)
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+func (dir *gitDirective) clone() {
+	log.Info().Str("repo", dir.repo).
+		Str("dest", dir.dest).
+		Msg("Cloning repository")
+
+	args := []string{"clone", "--quiet"}
+	if dir.submodules {
+		args = append(args, "--recurse-submodules")
+	}
+	args = append(args, "--", dir.repo, dir.dest)
+	// dest doesn't exist yet, so we can't run git from within it.
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		log.Error().Str("repo", dir.repo).
+			Str("dest", dir.dest).
+			Str("error", strings.TrimSpace(stderr.String())).
+			Msg("Failed to clone repository")
+		return
+	}
+
+	if err := dir.checkout(); err != nil {
+		log.Error().Str("dest", dir.dest).
+			Str("ref", dir.ref).
+			Str("error", err.Error()).
+			Msg("Failed to checkout ref")
+		return
+	}
+	if dir.ref != "" {
+		dir.updateSubmodules()
+	}
+}
+
+// bring an existing repository in line with ref, fetching from the remote
+// first when we're updating it.
+func (dir *gitDirective) sync() error {
+	if dir.update {
+		if _, err := dir.git("fetch", "--quiet", "--tags", "origin"); err != nil {
+			return err
+		}
+	}
+	if err := dir.checkout(); err != nil {
+		return err
+	}
+
+	if dir.update {
+		// fast forward the checked out branch, when there is one.
+		if _, err := dir.git("rev-parse", "--abbrev-ref", "@{upstream}"); err == nil {
+			if _, err := dir.git("merge", "--quiet", "--ff-only", "@{upstream}"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkout ref, unless it's already checked out.
+func (dir *gitDirective) checkout() error {
+	if dir.ref == "" {
+		return nil
+	}
+
+	want, err := dir.git("rev-parse", "--verify", "--quiet", dir.ref+"^{commit}")
+	if err != nil {
+		// ref may not have been fetched yet.
+		if _, err := dir.git("fetch", "--quiet", "--tags", "origin"); err != nil {
+			return err
+		}
+	}
+	if branch, _ := dir.git("symbolic-ref", "--quiet", "--short", "HEAD"); branch == dir.ref {
+		return nil
+	}
+	if head, _ := dir.git("rev-parse", "HEAD"); want != "" && head == want && !dir.refIsBranch() {
+		return nil
+	}
+
+	log.Info().Str("dest", dir.dest).
+		Str("ref", dir.ref).
+		Msg("Checking out ref")
+	_, err = dir.git("checkout", "--quiet", dir.ref)
+	return err
+}
+
+// whether ref is a branch, either locally or on the remote.
+func (dir *gitDirective) refIsBranch() bool {
+	for _, ref := range []string{"refs/heads/" + dir.ref, "refs/remotes/origin/" + dir.ref} {
+		if _, err := dir.git("show-ref", "--verify", "--quiet", ref); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (dir *gitDirective) updateSubmodules() {
+	if !dir.submodules {
+		return
+	}
+	if _, err := dir.git("submodule", "update", "--quiet", "--init", "--recursive"); err != nil {
+		log.Error().Str("dest", dir.dest).
+			Str("error", err.Error()).
+			Msg("Failed to update submodules")
+	}
+}
@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	fp "path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitRemoteIsLocal(t *testing.T) {
+	for repo, local := range map[string]bool{
+		"plugins/foo":                  true,
+		"/srv/git/foo.git":             true,
+		"./foo:bar":                    true,
+		"file:///srv/git/foo.git":      false,
+		"https://github.com/foo/bar":   false,
+		"git@github.com:foo/bar.git":   false,
+		"ssh://git@github.com/foo/bar": false,
+	} {
+		if res := gitRemoteIsLocal(repo); res != local {
+			t.Errorf("expected %s to be local=%t, got %t", repo, local, res)
+		}
+	}
+}
+
+func testGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=dotty", "GIT_AUTHOR_EMAIL=dotty@example.com",
+		"GIT_COMMITTER_NAME=dotty", "GIT_COMMITTER_EMAIL=dotty@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %s", args, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestGit_DoesntUpdateDirectoriesInsideOtherRepositories(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	// a home directory tracked by git, with an unrelated directory in it.
+	home := t.TempDir()
+	testGit(t, home, "init", "--quiet")
+	dest := fp.Join(home, ".config", "foo")
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fp.Join(dest, "bar"), []byte("bar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	testGit(t, home, "add", ".")
+	testGit(t, home, "commit", "--quiet", "-m", "home")
+	testGit(t, home, "commit", "--quiet", "--allow-empty", "-m", "more home")
+	head := testGit(t, home, "rev-parse", "HEAD")
+
+	dir := &gitDirective{repo: "https://example.com/foo.git", dest: dest, ref: "HEAD~1"}
+	if dir.isRepository() {
+		t.Fatal("directory inside of another repository was treated as a repository")
+	}
+	dir.Run()
+	if after := testGit(t, home, "rev-parse", "HEAD"); after != head {
+		t.Errorf("enclosing repository was changed from %s to %s", head, after)
+	}
+
+	if dir.dest = home; !dir.isRepository() {
+		t.Error("root of a repository wasn't treated as a repository")
+	}
+}
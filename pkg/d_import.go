@@ -62,7 +62,9 @@ func dImport(ctx *Context, args AnySlice) {
 
 				log.Info().Str("path", file).Msg("Importing config file")
 				LoadEdnSlice(file, func(conf AnySlice) {
-					dispatchDirectives(ctx.chdir(fp.Dir(file)), conf)
+					ctx := ctx.chdir(fp.Dir(file))
+					ctx.file = file
+					dispatchDirectives(ctx, conf)
 				})
 			}
 		},
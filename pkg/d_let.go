@@ -0,0 +1,34 @@
+package pkg
+
+import (
+	"github.com/rs/zerolog/log"
+	"olympos.io/encoding/edn"
+)
+
+/**
+ * Dispatch a body of directives with some definitions that only apply to it,
+ * leaving the context of everything after it unchanged. The bindings take the
+ * same form as the arguments to :def.
+ *   (:let ("XDG_CONFIG_HOME" "~/.config" (:link :mkdirs true))
+ *     (:link "nvim" "$XDG_CONFIG_HOME/nvim"))
+ */
+func dLet(ctx *Context, args AnySlice) {
+	if len(args) == 0 {
+		log.Error().Msgf("%s directive must be given a list of bindings", edn.Keyword("let"))
+		return
+	}
+	bindings, ok := args[0].(AnySlice)
+	if !ok {
+		log.Error().Interface("bindings", args[0]).
+			Msgf("%s bindings must be a list, not %T", edn.Keyword("let"), args[0])
+		return
+	}
+	if len(args) == 1 {
+		log.Warn().Msgf("Encountered %s directive with no body", edn.Keyword("let"))
+		return
+	}
+
+	scope := ctx.clone()
+	dDef(scope, bindings)
+	dispatchDirectives(scope, args[1:])
+}
@@ -0,0 +1,38 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDLet_ScopesDefinitionsToBody(t *testing.T) {
+	ctx := CreateContext()
+	ctx.Cwd, ctx.Home = "/cwd", "/home"
+	ctx.envOpts["DIR"] = "outer"
+
+	dirs := make(chan []string)
+	go func() {
+		var res []string
+		for dir := range ctx.DirChan {
+			mkdir := dir.(*mkdirDirective)
+			res = append(res, mkdir.path, formatMode(mkdir.chmod))
+		}
+		dirs <- res
+	}()
+	dispatchDirectives(ctx, pathsFromEdn(`(
+	  (:let ("DIR" "inner" (:mkdir :chmod "0700"))
+	    (:mkdir "$DIR"))
+	  (:mkdir "$DIR"))`))
+	close(ctx.DirChan)
+
+	expected := []string{"/cwd/inner", formatMode(0700), "/cwd/outer", formatMode(0744)}
+	if res := <-dirs; !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected directories %v, got %v", expected, res)
+	}
+	if val := ctx.envOpts["DIR"]; val != "outer" {
+		t.Errorf("expected :let to leave DIR unchanged, got %q", val)
+	}
+	if _, ok := ctx.mkdirOpts["chmod"]; ok {
+		t.Error("expected :let to leave mkdir options unchanged")
+	}
+}
@@ -0,0 +1,184 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"olympos.io/encoding/edn"
+)
+
+/**
+ * A directive to make sure a single line is present in (or absent from)
+ * a file, for files that can't just be replaced with a link.
+ *
+ * When regexp is given the last line matching it is replaced with line,
+ * otherwise line is appended to the file when it isn't already there. With
+ * absent every line matching regexp (or equal to line) is removed instead.
+ */
+type lineInFileDirective struct {
+	// the file being edited.
+	path string
+
+	// the line that should be in path.
+	line string
+
+	// a pattern for the line that should be replaced with line.
+	regexp *regexp.Regexp
+
+	// remove line from path, instead of adding it.
+	absent bool
+
+	// expand \1 style references in line to groups matched by regexp,
+	// leaving the file unchanged when nothing matches.
+	backrefs bool
+
+	// create path when it doesn't exist.
+	create bool
+
+	// edit path as root.
+	sudo bool
+}
+
+// a reference to a group matched by a regexp, such as \1.
+var backrefRegexp = regexp.MustCompile(`\\[0-9]+`)
+
+// replace every backref in template with the group it references in line,
+// given the submatch indexes of a regexp match against line. we don't use
+// regexp.Expand because lines often contain shell variables like $HOME.
+func expandBackrefs(template, line string, match []int) string {
+	return backrefRegexp.ReplaceAllStringFunc(template, func(ref string) string {
+		group, err := strconv.Atoi(ref[1:])
+		if err != nil || 2*group+1 >= len(match) || match[2*group] < 0 {
+			return ""
+		}
+		return line[match[2*group]:match[2*group+1]]
+	})
+}
+
+func dLineInFile(ctx *Context, args AnySlice) {
+	dPathValuePairs(ctx, args, "path", "line", func(path string, opts map[Any]Any) (directive, bool) {
+		return (&lineInFileDirective{path: path}).init(ctx, opts)
+	})
+}
+
+func (dir *lineInFileDirective) init(ctx *Context, opts map[Any]Any) (*lineInFileDirective, bool) {
+	var pattern string
+	ok := readMapOptionString(ctx.lineOpts, opts, &dir.line, "line", "")
+	ok = readMapOptionString(ctx.lineOpts, opts, &pattern, "regexp", "") && ok
+	ok = readMapOptionState(ctx.lineOpts, opts, &dir.absent) && ok
+	ok = readMapOptionBool(ctx.lineOpts, opts, &dir.backrefs, "backrefs", false) && ok
+	ok = readMapOptionBool(ctx.lineOpts, opts, &dir.create, "create", true) && ok
+	ok = readMapOptionBool(ctx.lineOpts, opts, &dir.sudo, "sudo", false) && ok
+	if !ok {
+		return dir, false
+	}
+
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Error().Str("path", dir.path).
+				Str("regexp", pattern).
+				Str("error", err.Error()).
+				Msg("Failed to compile line regexp")
+			return dir, false
+		}
+		dir.regexp = re
+	}
+
+	if dir.line == "" && (!dir.absent || dir.regexp == nil) {
+		log.Error().Str("path", dir.path).
+			Msgf("%s directive must specify a %s", edn.Keyword("line-in-file"), edn.Keyword("line"))
+		return dir, false
+	}
+	if dir.backrefs && dir.regexp == nil {
+		log.Error().Str("path", dir.path).
+			Msgf("%s requires a %s", edn.Keyword("backrefs"), edn.Keyword("regexp"))
+		return dir, false
+	}
+	return dir, true
+}
+
+func (dir *lineInFileDirective) Log() string {
+	var flags string
+	if dir.sudo {
+		flags += "sudo "
+	}
+	if dir.absent {
+		flags += "--absent "
+	}
+	if dir.regexp != nil {
+		flags += fmt.Sprintf("--regexp %q ", dir.regexp.String())
+	}
+	return fmt.Sprintf("line-in-file %s%s %q", flags, dir.path, dir.line)
+}
+
+func (dir *lineInFileDirective) Run() {
+	changed, err := editFile(fileOps{dir.sudo}, dir.path, dir.create, dir.edit)
+	if err != nil {
+		log.Error().Str("path", dir.path).
+			Str("line", dir.line).
+			Str("error", err.Error()).
+			Msg("Failed to edit line in file")
+	} else if changed {
+		log.Info().Str("path", dir.path).
+			Str("line", dir.line).
+			Bool("absent", dir.absent).
+			Msg("Edited line in file")
+	} else {
+		log.Debug().Str("path", dir.path).
+			Str("line", dir.line).
+			Msg("Skipping line in file because it's already up to date")
+	}
+}
+
+func (dir *lineInFileDirective) Diff(w io.Writer) {
+	diffEditFile(w, dir.path, dir.edit)
+}
+
+// whether line is the one this directive is looking for.
+func (dir *lineInFileDirective) matches(line string) bool {
+	if dir.regexp != nil {
+		return dir.regexp.MatchString(line)
+	}
+	return line == dir.line
+}
+
+func (dir *lineInFileDirective) edit(lines []string) ([]string, error) {
+	if dir.absent {
+		res := make([]string, 0, len(lines))
+		for _, line := range lines {
+			if !dir.matches(line) {
+				res = append(res, line)
+			}
+		}
+		return res, nil
+	}
+
+	if dir.regexp != nil {
+		for i := len(lines) - 1; i >= 0; i-- {
+			match := dir.regexp.FindStringSubmatchIndex(lines[i])
+			if match == nil {
+				continue
+			}
+			if dir.backrefs {
+				lines[i] = expandBackrefs(dir.line, lines[i], match)
+			} else {
+				lines[i] = dir.line
+			}
+			return lines, nil
+		}
+		if dir.backrefs {
+			return lines, nil
+		}
+	}
+
+	for _, line := range lines {
+		if line == dir.line {
+			return lines, nil
+		}
+	}
+	return append(lines, dir.line), nil
+}
@@ -0,0 +1,99 @@
+package pkg
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// apply edit to content twice, asserting the second pass changes nothing.
+func editTwice(t *testing.T, content string, edit func([]string) ([]string, error)) string {
+	lines, eol := splitFileLines(content)
+	edited, err := edit(lines)
+	if err != nil {
+		t.Fatalf("edit failed: %s", err)
+	}
+	if !linesEqual(lines, edited) {
+		eol = true
+	}
+	res := joinFileLines(edited, eol)
+
+	again, err := edit(append([]string(nil), edited...))
+	if err != nil {
+		t.Fatalf("second edit failed: %s", err)
+	}
+	if !linesEqual(edited, again) {
+		t.Errorf("edit wasn't idempotent, got %q then %q", edited, again)
+	}
+	return res
+}
+
+func TestLineInFile_AppendsMissingLine(t *testing.T) {
+	dir := &lineInFileDirective{line: "baz"}
+	if res := editTwice(t, "foo\nbar", dir.edit); res != "foo\nbar\nbaz\n" {
+		t.Errorf("unexpected result %q", res)
+	}
+}
+
+func TestLineInFile_ReplacesLastMatchingLine(t *testing.T) {
+	dir := &lineInFileDirective{line: "FOO=3", regexp: regexp.MustCompile("^FOO=")}
+	if res := editTwice(t, "FOO=1\nBAR=1\nFOO=2\n", dir.edit); res != "FOO=1\nBAR=1\nFOO=3\n" {
+		t.Errorf("unexpected result %q", res)
+	}
+}
+
+func TestLineInFile_ExpandsBackrefs(t *testing.T) {
+	dir := &lineInFileDirective{
+		line:     `PATH=$HOME/bin:\1`,
+		regexp:   regexp.MustCompile(`^PATH=([^$].*)$`),
+		backrefs: true,
+	}
+	if res := editTwice(t, "PATH=/usr/bin\n", dir.edit); res != "PATH=$HOME/bin:/usr/bin\n" {
+		t.Errorf("unexpected result %q", res)
+	}
+}
+
+func TestLineInFile_RemovesAbsentLines(t *testing.T) {
+	dir := &lineInFileDirective{regexp: regexp.MustCompile("^#"), absent: true}
+	if res := editTwice(t, "# foo\nbar\n# baz\n", dir.edit); res != "bar\n" {
+		t.Errorf("unexpected result %q", res)
+	}
+}
+
+func TestBlockInFile_AddsAndReplacesBlock(t *testing.T) {
+	dir := &blockInFileDirective{block: []string{"foo", "bar"}, marker: "# {mark} dotty"}
+	res := editTwice(t, "before\n", dir.edit)
+	if res != "before\n# BEGIN dotty\nfoo\nbar\n# END dotty\n" {
+		t.Errorf("unexpected result %q", res)
+	}
+
+	dir.block = []string{"baz"}
+	res = editTwice(t, res+"after\n", dir.edit)
+	if res != "before\n# BEGIN dotty\nbaz\n# END dotty\nafter\n" {
+		t.Errorf("unexpected result %q", res)
+	}
+}
+
+func TestBlockInFile_RemovesAbsentBlock(t *testing.T) {
+	dir := &blockInFileDirective{marker: "# {mark} dotty", absent: true}
+	res := editTwice(t, "foo\n# BEGIN dotty\nbar\n# END dotty\nbaz\n", dir.edit)
+	if res != "foo\nbaz\n" {
+		t.Errorf("unexpected result %q", res)
+	}
+}
+
+func TestBlockInFile_FailsOnUnterminatedBlock(t *testing.T) {
+	dir := &blockInFileDirective{block: []string{"foo"}, marker: "# {mark} dotty"}
+	lines, _ := splitFileLines("# BEGIN dotty\nfoo\n")
+	if _, err := dir.edit(lines); err == nil || !strings.Contains(err.Error(), "END") {
+		t.Errorf("expected an error about the missing end marker, got %v", err)
+	}
+}
+
+func TestSplitFileLines_RoundTrips(t *testing.T) {
+	for _, content := range []string{"", "\n", "foo", "foo\n", "foo\n\nbar"} {
+		if res := joinFileLines(splitFileLines(content)); res != content {
+			t.Errorf("expected %q, got %q", content, res)
+		}
+	}
+}
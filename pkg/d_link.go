@@ -2,6 +2,8 @@ package pkg
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	fp "path/filepath"
 	"strings"
@@ -33,6 +35,58 @@ type linkDirective struct {
 
 	/** make a symlink, not an hard link */
 	symbolic bool
+
+	/** move an existing dest file into src before linking */
+	adopt bool
+
+	/** glob patterns for files that globbed sources shouldn't match */
+	exclude []string
+
+	/** keep globbed sources path (relative to the glob base) under dest */
+	preserveStructure bool
+
+	/** ignore files whose patterns globbed sources shouldn't match */
+	ignores []*ignoreFile
+
+	/** ask the user what to do when dest exists and isn't linked to src */
+	interactive bool
+
+	/**
+	 * permissions for the linked file. symlinks don't have permissions of their
+	 * own, so these are intentionally applied through the link to src (such as
+	 * a private key in your dotfiles that ssh refuses to use unless it's 0600).
+	 */
+	mode    os.FileMode
+	modeSet bool
+
+	/** permissions for any parent directories created for dest */
+	dirMode    os.FileMode
+	dirModeSet bool
+
+	/** perform any file operations as root */
+	sudo bool
+
+	/** the config file this directive was defined in */
+	origin string
+
+	/** resolved src and dest pairs that shouldn't be linked, see linkConflicts */
+	skip map[linkPair]bool
+
+	/** the dest each src and dest pair was resolved to when planned */
+	planned map[linkPair]string
+}
+
+// a src file and the exact path it's linked to.
+type linkPair struct {
+	src  string
+	dest string
+}
+
+// a source file for a link and the path it should be given under a
+// destination directory.
+type linkSource struct {
+	path string
+	rel  string
 }
 
 // generate the paths for a link (src or dest) from arg.
@@ -43,11 +97,17 @@ type linkDirective struct {
 //
 // logTitle is used to let include which path type (src or dest) we're
 // building in the logging output.
-func dLinkGeneratePaths(cwd string, eval func(string) (string, bool), arg Any, logTitle string) ([]string, bool) {
+func dLinkGeneratePaths(cwd string, eval func(string) ([]string, bool), arg Any, logTitle string) ([]string, bool) {
 	if str, ok := arg.(string); ok {
-		if str, ok = eval(str); ok {
-			return []string{JoinPath(cwd, fp.FromSlash(str))}, true
+		strs, ok := eval(str)
+		if !ok {
+			return nil, false
 		}
+		paths := make([]string, len(strs))
+		for i, str := range strs {
+			paths[i] = JoinPath(cwd, fp.FromSlash(str))
+		}
+		return paths, true
 	} else if slice, ok := arg.(AnySlice); ok {
 		ch, paths := make(chan string), make([]string, 0)
 		go recursiveBuildPath(ch, slice, cwd, eval, func(_ string, arg Any) {
@@ -95,14 +155,16 @@ LoopStart:
 						Msgf("Link directive must specify a %s", edn.Keyword(path.field))
 					continue LoopStart
 				}
-				if paths, ok := dLinkGeneratePaths(ctx.Cwd, ctx.eval, arg, path.field); ok {
+				if paths, ok := dLinkGeneratePaths(ctx.Cwd, ctx.evalPaths, arg, path.field); ok {
 					path.paths = paths
 				} else {
 					continue LoopStart
 				}
 			}
 
-			ctx.DirChan <- (&linkDirective{src: paths[0].paths, dest: paths[1].paths}).init(ctx, pathMap)
+			if dir, ok := (&linkDirective{src: paths[0].paths, dest: paths[1].paths}).init(ctx, pathMap); ok {
+				ctx.DirChan <- dir
+			}
 		} else {
 			if i == len(args)-1 {
 				log.Error().Interface("src", path).
@@ -112,16 +174,18 @@ LoopStart:
 
 			i++
 			// NOTE cleaning up this duplication would take even more lines, so lets leave it.
-			src, ok := dLinkGeneratePaths(ctx.Cwd, ctx.eval, path, "src")
+			src, ok := dLinkGeneratePaths(ctx.Cwd, ctx.evalPaths, path, "src")
 			if !ok {
 				continue
 			}
-			dest, ok := dLinkGeneratePaths(ctx.Cwd, ctx.eval, args[i], "dest")
+			dest, ok := dLinkGeneratePaths(ctx.Cwd, ctx.evalPaths, args[i], "dest")
 			if !ok {
 				continue
 			}
 
-			ctx.DirChan <- (&linkDirective{src: src, dest: dest}).init(ctx, nil)
+			if dir, ok := (&linkDirective{src: src, dest: dest}).init(ctx, nil); ok {
+				ctx.DirChan <- dir
+			}
 		}
 	}
 }
@@ -129,7 +193,10 @@ LoopStart:
 /**
  * populate directive defaults from either the context or current options.
  */
-func (dir *linkDirective) init(ctx *Context, opts map[Any]Any) *linkDirective {
+func (dir *linkDirective) init(ctx *Context, opts map[Any]Any) (*linkDirective, bool) {
+	dir.origin = ctx.file
+	dir.skip = make(map[linkPair]bool)
+	dir.planned = make(map[linkPair]string)
 	for _, slice := range [][]string{dir.src, dir.dest} {
 		for i := range slice {
 			slice[i] = ExpandTilde(ctx.Home, slice[i])
@@ -142,6 +209,28 @@ func (dir *linkDirective) init(ctx *Context, opts map[Any]Any) *linkDirective {
 	readMapOptionBool(ctx.linkOpts, opts, &dir.glob, "glob", false)
 	readMapOptionBool(ctx.linkOpts, opts, &dir.ignoreMissing, "ignore-missing", false)
 	readMapOptionBool(ctx.linkOpts, opts, &dir.symbolic, "symbolic", true)
+	readMapOptionBool(ctx.linkOpts, opts, &dir.adopt, "adopt", false)
+	readMapOptionStrings(ctx.linkOpts, opts, &dir.exclude, "exclude", nil)
+	readMapOptionBool(ctx.linkOpts, opts, &dir.preserveStructure, "preserve-structure", false)
+	readMapOptionBool(ctx.linkOpts, opts, &dir.interactive, "interactive", false)
+	readMapOptionBool(ctx.linkOpts, opts, &dir.sudo, "sudo", false)
+	ok := readMapOptionMode(ctx.linkOpts, opts, &dir.mode, "mode", 0)
+	ok = readMapOptionMode(ctx.linkOpts, opts, &dir.dirMode, "dir-mode", 0) && ok
+	if !ok {
+		return dir, false
+	}
+	dir.modeSet = dir.mode != 0
+	dir.dirModeSet = dir.dirMode != 0
+	if !dir.dirModeSet {
+		dir.dirMode = 0744
+	}
+
+	if dir.glob {
+		dir.ignores = []*ignoreFile{loadIgnoreFile(ctx.Root)}
+		if ctx.Cwd != ctx.Root {
+			dir.ignores = append(dir.ignores, loadIgnoreFile(ctx.Cwd))
+		}
+	}
 
 	// linking multiple files into one (or more) destinations. Make sure
 	// each destination has a trailing slash to indicate it's a directory.
@@ -153,7 +242,7 @@ func (dir *linkDirective) init(ctx *Context, opts map[Any]Any) *linkDirective {
 		}
 	}
 
-	return dir
+	return dir, true
 }
 
 func (dir *linkDirective) Log() string {
@@ -166,6 +255,12 @@ func (dir *linkDirective) Log() string {
 	if dir.force {
 		prefix += "f"
 	}
+	if dir.adopt {
+		prefix = "adopt " + prefix
+	}
+	if dir.sudo {
+		prefix = "sudo " + prefix
+	}
 	if dir.glob {
 		prefix = "glob " + prefix
 	}
@@ -182,16 +277,23 @@ func (dir *linkDirective) Log() string {
 }
 
 func (dir *linkDirective) Run() {
-	srcCh := make(chan string)
+	srcCh := make(chan linkSource)
 	go dir.linkSources(srcCh)
 
 	// TODO some heavy refactoring. There's a lot of edge cases here
 	// so it's easier to keep it all in one place, but this should really
 	// be broken down.
-	for src := range srcCh {
-		for _, dest := range dir.dest {
-			if strings.HasSuffix(dest, string(fp.Separator)) {
-				dest = JoinPath(dest, fp.Base(src))
+	for linkSrc := range srcCh {
+		src := linkSrc.path
+		for _, destArg := range dir.dest {
+			// link to the dest this was planned with, so the links we skip are
+			// exactly the ones reported as conflicting.
+			dest := dir.plannedDest(linkSrc, destArg)
+			if dir.skip[linkPair{src, dest}] {
+				log.Debug().Str("src", src).
+					Str("dest", dest).
+					Msg("Skipping link because it conflicts with another link")
+				continue
 			}
 
 			destInfo, err := os.Lstat(dest)
@@ -210,7 +312,11 @@ func (dir *linkDirective) Run() {
 				}
 			}
 
-			if destExists {
+			if destExists && dir.adopt && destInfo.Mode().IsRegular() {
+				if !dir.adoptDest(src, dest) {
+					continue
+				}
+			} else if destExists {
 				if dir.force || (dir.relink && destInfo.Mode()&os.ModeSymlink != 0) {
 					if destInfo.IsDir() {
 						// it's not safe to recursively delete a directory and replace
@@ -220,7 +326,7 @@ func (dir *linkDirective) Run() {
 							Msg("Skipping force link because dest is a directory")
 						continue
 					}
-					if err := os.Remove(dest); err != nil {
+					if err := dir.ops().remove(dest); err != nil {
 						log.Error().Str("src", src).
 							Str("dest", dest).
 							Str("error", err.Error()).
@@ -228,8 +334,13 @@ func (dir *linkDirective) Run() {
 						continue
 					}
 				} else {
-					if destInfo.IsDir() {
+					if destInfo.IsDir() && dest == destArg {
+						// dest only became a directory after this link was planned.
 						dest = JoinPath(dest, fp.Base(src))
+					} else if !destInfo.IsDir() && dir.interactive && !dir.linksTo(src, dest, destInfo) {
+						if !dir.resolveExisting(src, dest) {
+							continue
+						}
 					} else {
 						// NOTE this has debug level because linking a file to a file that exists
 						// is pretty common... I.E. when you're linking a file to the same file it's
@@ -237,10 +348,23 @@ func (dir *linkDirective) Run() {
 						log.Debug().Str("src", src).
 							Str("dest", dest).
 							Msg("Skipping linking src to dest because dest exists.")
+						if dir.modeSet && dir.linksTo(src, dest, destInfo) {
+							reconcileMode(dir.ops(), dest, dir.mode)
+						}
 						continue
 					}
 				}
 			} else {
+				if dir.adopt && !dir.ignoreMissing {
+					// adopting passes through missing sources, but there's nothing
+					// to adopt them from when dest doesn't exist either.
+					if srcExists, err := pathExists(src, true); err != nil || !srcExists {
+						log.Error().Str("path", src).
+							Msg("Link src not found")
+						continue
+					}
+				}
+
 				destParent := fp.Dir(dest)
 				if destParentExists, err := dirExists(destParent, true); err != nil {
 					log.Error().Str("src", src).
@@ -251,12 +375,15 @@ func (dir *linkDirective) Run() {
 					continue
 				} else if !destParentExists {
 					if dir.mkdirs {
-						// WARN hardcoded file permission
-						if err := os.MkdirAll(destParent, 0744); err != nil {
+						if err := dir.ops().mkdirAll(destParent, dir.dirMode); err != nil {
 							log.Error().Str("path", destParent).
 								Msg("Failed to create parent directory for dest")
 							continue
 						}
+						if dir.dirModeSet {
+							// the permissions given to MkdirAll are masked by the umask.
+							reconcileMode(dir.ops(), destParent, dir.dirMode)
+						}
 					} else {
 						log.Warn().Str("src", src).
 							Str("dest", dest).
@@ -274,6 +401,8 @@ func (dir *linkDirective) Run() {
 					Str("dest", dest).
 					Str("error", err.Error()).
 					Msg("Failed to link files")
+			} else if dir.modeSet {
+				reconcileMode(dir.ops(), dest, dir.mode)
 			}
 		}
 	}
@@ -282,43 +411,51 @@ func (dir *linkDirective) Run() {
 // The function used to link this kind of directive (symbolic or hard link).
 func (dir *linkDirective) linker() func(string, string) error {
 	if dir.symbolic {
-		return os.Symlink
+		return dir.ops().symlink
 	}
 
-	return os.Link
+	return dir.ops().link
+}
+
+// the file operations used by this directive (as root or the current user).
+func (dir *linkDirective) ops() fileOps {
+	return fileOps{dir.sudo}
 }
 
 // pass list of files to be linked from the sources for this
 // directive into ch.
 //
-// This also expands any globs when dir.glob is true.
+// This also expands any globs when dir.glob is true, skipping any
+// files that are excluded or ignored.
 //
 // WARN when expanding globs, there's a chance no files will
 // be returned.
-func (dir *linkDirective) linkSources(ch chan string) {
+func (dir *linkDirective) linkSources(ch chan linkSource) {
 	defer close(ch)
 	for _, src := range dir.src {
 		if dir.glob {
-			if globs, err := fp.Glob(src); err != nil {
+			srcs, err := dir.globSources(src)
+			if err != nil {
 				log.Error().Str("glob", src).
 					Str("error", err.Error()).
 					Msg("Glob failed")
-			} else {
-				for _, path := range globs {
-					ch <- path
-				}
+			}
+			for _, linkSrc := range srcs {
+				ch <- linkSrc
 			}
 		} else {
 			// we're linking from file to file, first check whether the file exists
 			// or if we don't care, then return the file as is.
-			if dir.symbolic && dir.ignoreMissing {
-				ch <- src
+			if (dir.symbolic && dir.ignoreMissing) || dir.adopt {
+				// when adopting a missing src may be moved in from dest, so
+				// defer checking whether it exists until we've seen dest.
+				ch <- linkSource{src, fp.Base(src)}
 			} else if exists, err := pathExists(src, true); err != nil {
 				log.Error().Str("path", src).
 					Str("error", err.Error()).
 					Msg("Error when checking file exists")
 			} else if exists {
-				ch <- src
+				ch <- linkSource{src, fp.Base(src)}
 			} else {
 				log.Error().Str("path", src).
 					Msg("Link src not found")
@@ -326,3 +463,304 @@ func (dir *linkDirective) linkSources(ch chan string) {
 		}
 	}
 }
+
+// expand the glob pattern src into the sources it matches, skipping any
+// files that are excluded or ignored.
+func (dir *linkDirective) globSources(src string) ([]linkSource, error) {
+	globs, err := glob(src)
+	if err != nil {
+		return nil, err
+	}
+
+	base := globBase(src)
+	srcs := make([]linkSource, 0, len(globs))
+GlobLoop:
+	for _, path := range globs {
+		rel, err := fp.Rel(base, path)
+		if err != nil {
+			rel = fp.Base(path)
+		}
+		if globMatchesAny(dir.exclude, rel) {
+			log.Trace().Str("path", path).
+				Msg("Skipping excluded glob match")
+			continue
+		}
+		for _, ignore := range dir.ignores {
+			if ignore.ignores(path) {
+				log.Trace().Str("path", path).
+					Str("ignore-file", JoinPath(ignore.dir, ignoreFileName)).
+					Msg("Skipping ignored glob match")
+				continue GlobLoop
+			}
+		}
+
+		if !dir.preserveStructure {
+			rel = fp.Base(path)
+		}
+		srcs = append(srcs, linkSource{path, rel})
+	}
+	return srcs, nil
+}
+
+// the exact path that src will be linked to when linked into dest. This
+// follows the same rules as Run, but doesn't modify the file system.
+func (dir *linkDirective) resolveDest(src linkSource, dest string) string {
+	if strings.HasSuffix(dest, string(fp.Separator)) {
+		return JoinPath(dest, src.rel)
+	}
+	if !dir.force {
+		if isDir, err := dirExists(dest, false); err == nil && isDir {
+			return JoinPath(dest, fp.Base(src.path))
+		}
+	}
+	return dest
+}
+
+// the exact path src is linked to under dest, resolved only the first time
+// it's requested so it stays the same from planning the directive to running it.
+func (dir *linkDirective) plannedDest(src linkSource, dest string) string {
+	key := linkPair{src.path, dest}
+	if resolved, ok := dir.planned[key]; ok {
+		return resolved
+	}
+	resolved := dir.resolveDest(src, dest)
+	dir.planned[key] = resolved
+	return resolved
+}
+
+// every src and dest pair this directive is expected to link. Unlike
+// linkSources this doesn't report missing sources, because they may be
+// created by earlier directives before this one runs.
+func (dir *linkDirective) plannedLinks() []linkPair {
+	pairs := make([]linkPair, 0, len(dir.src)*len(dir.dest))
+	for _, src := range dir.src {
+		srcs := []linkSource{{src, fp.Base(src)}}
+		if dir.glob {
+			srcs, _ = dir.globSources(src)
+		}
+
+		for _, linkSrc := range srcs {
+			for _, dest := range dir.dest {
+				pairs = append(pairs, linkPair{linkSrc.path, dir.plannedDest(linkSrc, dest)})
+			}
+		}
+	}
+	return pairs
+}
+
+// assert whether dest (with info destInfo) is already the link this directive
+// would create from src.
+func (dir *linkDirective) linksTo(src, dest string, destInfo os.FileInfo) bool {
+	if dir.symbolic {
+		target, err := os.Readlink(dest)
+		return err == nil && target == src
+	}
+
+	srcInfo, err := os.Stat(src)
+	return err == nil && os.SameFile(srcInfo, destInfo)
+}
+
+// the choices for resolving an existing dest in interactive mode.
+const (
+	linkChoiceSkip      = "s"
+	linkChoiceOverwrite = "o"
+	linkChoiceBackup    = "b"
+	linkChoiceAdopt     = "a"
+	linkChoiceDiff      = "d"
+)
+
+// a choice the user has asked to apply to every remaining existing dest.
+var linkChoiceForAll string
+
+// ask the user what to do with the file at dest that's in the way of linking
+// src to it. Returns whether dest has been cleared and the link can be made.
+func (dir *linkDirective) resolveExisting(src, dest string) bool {
+	choice := linkChoiceForAll
+	for choice == "" {
+		answer := promptUser(fmt.Sprintf("%s exists: [s]kip, [o]verwrite, [b]ackup and overwrite, [a]dopt, show [d]iff (capitalise to apply to all)?", dest))
+		if answer == "" {
+			// default to the safest choice, this also stops us looping
+			// forever when stdin has been closed.
+			answer = linkChoiceSkip
+		}
+
+		choice = strings.ToLower(answer)
+		if choice == linkChoiceDiff {
+			dir.printDiff(os.Stderr, src, dest)
+			choice = ""
+			continue
+		}
+		if answer != choice {
+			linkChoiceForAll = choice
+		}
+	}
+
+	switch choice {
+	case linkChoiceSkip:
+		log.Info().Str("src", src).
+			Str("dest", dest).
+			Msg("Skipping linking src to dest because dest exists")
+		return false
+	case linkChoiceOverwrite:
+		if err := dir.ops().remove(dest); err != nil {
+			log.Error().Str("src", src).
+				Str("dest", dest).
+				Str("error", err.Error()).
+				Msg("Failed to remove dest before relink, skipping")
+			return false
+		}
+		return true
+	case linkChoiceBackup:
+		backup := dest + ".dotty-backup"
+		for i := 1; ; i++ {
+			if exists, _ := pathExists(backup, false); !exists {
+				break
+			}
+			backup = fmt.Sprintf("%s.dotty-backup.%d", dest, i)
+		}
+		log.Info().Str("dest", dest).
+			Str("backup", backup).
+			Msg("Backing up dest")
+		if err := dir.ops().rename(dest, backup); err != nil {
+			log.Error().Str("dest", dest).
+				Str("backup", backup).
+				Str("error", err.Error()).
+				Msg("Failed to backup dest, skipping")
+			return false
+		}
+		return true
+	case linkChoiceAdopt:
+		return dir.adoptDest(src, dest)
+	default:
+		log.Warn().Str("choice", choice).
+			Str("dest", dest).
+			Msg("Unknown choice, skipping")
+		return false
+	}
+}
+
+// write a unified diff from the current contents of dest to src into w.
+func (dir *linkDirective) printDiff(w io.Writer, src, dest string) {
+	destBytes, err := ioutil.ReadFile(dest)
+	if err != nil {
+		log.Error().Str("dest", dest).
+			Str("error", err.Error()).
+			Msg("Failed to read dest")
+		return
+	}
+	srcBytes, err := ioutil.ReadFile(src)
+	if err != nil && !os.IsNotExist(err) {
+		log.Error().Str("src", src).
+			Str("error", err.Error()).
+			Msg("Failed to read src")
+		return
+	}
+	fmt.Fprint(w, unifiedDiff(dest, src, string(destBytes), string(srcBytes)))
+}
+
+func (dir *linkDirective) Diff(w io.Writer) {
+	for _, pair := range dir.plannedLinks() {
+		if dir.skip[pair] {
+			continue
+		}
+
+		destInfo, err := os.Lstat(pair.dest)
+		if err != nil || destInfo.IsDir() {
+			continue
+		}
+		if dir.linksTo(pair.src, pair.dest, destInfo) {
+			// nothing would be lost by linking to dest, but it's permissions
+			// may have drifted.
+			if info, err := os.Stat(pair.dest); err == nil && dir.modeSet {
+				if current := info.Mode() & chmodBits; current != dir.mode {
+					fmt.Fprintf(w, "mode %s => %s %s\n", formatMode(current), formatMode(dir.mode), pair.dest)
+				}
+			}
+			continue
+		}
+		if srcIsDir, _ := dirExists(pair.src, true); srcIsDir {
+			log.Debug().Str("src", pair.src).
+				Str("dest", pair.dest).
+				Msg("Skipping diff because src is a directory")
+			continue
+		}
+		if destExists, _ := fileExists(pair.dest, true); !destExists {
+			// dest is a broken symlink.
+			continue
+		}
+
+		dir.printDiff(w, pair.src, pair.dest)
+	}
+}
+
+// move the existing file at dest into the dotfiles at src, so dest can then
+// be replaced with a link back to it. When src already exists and differs from
+// dest the user is asked to confirm before src is overwritten.
+func (dir *linkDirective) adoptDest(src, dest string) bool {
+	srcInfo, err := os.Stat(src)
+	if err != nil && !os.IsNotExist(err) {
+		log.Error().Str("src", src).
+			Str("error", err.Error()).
+			Msg("Failed to stat src before adopting dest")
+		return false
+	}
+
+	if err == nil {
+		if srcInfo.IsDir() {
+			log.Warn().Str("src", src).
+				Str("dest", dest).
+				Msg("Skipping adopting dest because src is a directory")
+			return false
+		}
+
+		if same, err := filesEqual(src, dest); err != nil {
+			log.Error().Str("src", src).
+				Str("dest", dest).
+				Str("error", err.Error()).
+				Msg("Failed to compare src and dest")
+			return false
+		} else if same {
+			log.Info().Str("src", src).
+				Str("dest", dest).
+				Msg("Dest matches src, replacing it with a link")
+			if err := dir.ops().remove(dest); err != nil {
+				log.Error().Str("dest", dest).
+					Str("error", err.Error()).
+					Msg("Failed to remove dest before linking")
+				return false
+			}
+			return true
+		} else if !promptConfirm(fmt.Sprintf("Overwrite %s with %s", src, dest)) {
+			log.Warn().Str("src", src).
+				Str("dest", dest).
+				Msg("Skipping adopting dest because src would be overwritten")
+			return false
+		}
+	} else if err := os.MkdirAll(fp.Dir(src), 0744); err != nil {
+		log.Error().Str("path", fp.Dir(src)).
+			Str("error", err.Error()).
+			Msg("Failed to create parent directory for src")
+		return false
+	}
+
+	log.Info().Str("src", src).
+		Str("dest", dest).
+		Msg("Adopting dest into src")
+	if err := dir.ops().rename(dest, src); err != nil {
+		log.Error().Str("src", src).
+			Str("dest", dest).
+			Str("error", err.Error()).
+			Msg("Failed to move dest into src")
+		return false
+	}
+	if dir.sudo {
+		// dest was moved as root, but src should belong to the user whose
+		// dotfiles it's now in.
+		if err := dir.ops().chown(src, os.Getuid(), os.Getgid()); err != nil {
+			log.Error().Str("src", src).
+				Str("error", err.Error()).
+				Msg("Failed to give adopted src back to the current user")
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+// a single planned link and the directive it belongs to.
+type plannedLink struct {
+	linkPair
+	dir *linkDirective
+
+	// whether src is a directory, meaning anything linked inside of
+	// dest is actually linked into src.
+	srcIsDir bool
+}
+
+/**
+ * Read every directive from ctx.DirChan and check the links among them
+ * for any conflicting destinations before returning them.
+ *
+ * A conflict is when two different sources are linked to the same dest,
+ * or when a dest is inside the dest of another link to a directory (meaning
+ * the link would be made inside of your dotfiles). By default the first
+ * definition wins and each conflict is reported as an error. When override
+ * is true later definitions win instead and conflicts are only warned about.
+ */
+func PlanDirectives(ctx *Context, override bool) []directive {
+	dirs := make([]directive, 0)
+	links := make([]plannedLink, 0)
+	for dir := range ctx.DirChan {
+		dirs = append(dirs, dir)
+
+		if link, ok := dir.(*linkDirective); ok {
+			for _, pair := range link.plannedLinks() {
+				isDir, _ := dirExists(pair.src, true)
+				links = append(links, plannedLink{pair, link, isDir})
+			}
+		}
+	}
+
+	linkConflicts(links, override)
+	return dirs
+}
+
+// find and resolve any conflicts between links, see PlanDirectives.
+func linkConflicts(links []plannedLink, override bool) {
+	for i, later := range links {
+		for _, earlier := range links[:i] {
+			var msg string
+			if earlier.dest == later.dest {
+				if earlier.src == later.src {
+					continue
+				}
+				msg = "Multiple links have the same destination"
+			} else if earlier.srcIsDir && fileIsRelative(later.dest, earlier.dest) {
+				msg = "Link destination is inside another linked directory"
+			} else if later.srcIsDir && fileIsRelative(earlier.dest, later.dest) {
+				msg = "Linked directory contains another link destination"
+			} else {
+				continue
+			}
+
+			event := log.Error()
+			if override {
+				event = log.Warn()
+			}
+			event.Str("src", later.src).
+				Str("dest", later.dest).
+				Str("origin", later.dir.origin).
+				Str("conflict-src", earlier.src).
+				Str("conflict-dest", earlier.dest).
+				Str("conflict-origin", earlier.dir.origin).
+				Msg(msg)
+
+			if override {
+				earlier.dir.skip[earlier.linkPair] = true
+			} else {
+				later.dir.skip[later.linkPair] = true
+			}
+		}
+	}
+}
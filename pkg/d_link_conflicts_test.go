@@ -0,0 +1,83 @@
+package pkg
+
+import (
+	"os"
+	"testing"
+)
+
+func plannedLinkFor(src, dest string, srcIsDir bool) plannedLink {
+	return plannedLink{
+		linkPair{src, dest},
+		&linkDirective{skip: make(map[linkPair]bool)},
+		srcIsDir,
+	}
+}
+
+func TestLinkConflicts_FirstDefinitionWinsByDefault(t *testing.T) {
+	first := plannedLinkFor("/dots/foo", "/home/.foo", false)
+	second := plannedLinkFor("/dots/bar", "/home/.foo", false)
+	linkConflicts([]plannedLink{first, second}, false)
+
+	if first.dir.skip[first.linkPair] {
+		t.Error("first definition was skipped")
+	}
+	if !second.dir.skip[second.linkPair] {
+		t.Error("conflicting second definition wasn't skipped")
+	}
+}
+
+func TestLinkConflicts_LaterDefinitionsCanOverride(t *testing.T) {
+	first := plannedLinkFor("/dots/foo", "/home/.foo", false)
+	second := plannedLinkFor("/dots/bar", "/home/.foo", false)
+	linkConflicts([]plannedLink{first, second}, true)
+
+	if !first.dir.skip[first.linkPair] {
+		t.Error("overridden first definition wasn't skipped")
+	}
+	if second.dir.skip[second.linkPair] {
+		t.Error("overriding second definition was skipped")
+	}
+}
+
+func TestLinkConflicts_DetectsLinksInsideLinkedDirectories(t *testing.T) {
+	links := []plannedLink{
+		plannedLinkFor("/dots/conf", "/home/.conf", true),
+		plannedLinkFor("/dots/foo", "/home/.conf/foo", false),
+		plannedLinkFor("/dots/bar", "/home/.config/bar", false),
+	}
+	linkConflicts(links, false)
+
+	if !links[1].dir.skip[links[1].linkPair] {
+		t.Error("link inside of linked directory wasn't skipped")
+	}
+	if links[2].dir.skip[links[2].linkPair] {
+		t.Error("link in sibling directory with common prefix was skipped")
+	}
+}
+
+func TestLinkConflicts_IgnoresDuplicateLinks(t *testing.T) {
+	first := plannedLinkFor("/dots/foo", "/home/.foo", false)
+	second := plannedLinkFor("/dots/foo", "/home/.foo", false)
+	linkConflicts([]plannedLink{first, second}, false)
+
+	if first.dir.skip[first.linkPair] || second.dir.skip[second.linkPair] {
+		t.Error("identical links were treated as conflicting")
+	}
+}
+
+func TestPlannedLinks_DestIsResolvedOnce(t *testing.T) {
+	dest := t.TempDir()
+	dir, _ := (&linkDirective{src: []string{"/dots/foo"}, dest: []string{dest}}).init(CreateContext(), nil)
+	expected := []linkPair{{"/dots/foo", JoinPath(dest, "foo")}}
+	if pairs := dir.plannedLinks(); len(pairs) != 1 || pairs[0] != expected[0] {
+		t.Fatalf("expected link into existing directory %v, got %v", expected, pairs)
+	}
+
+	// removing the directory after planning shouldn't change where we link to.
+	if err := os.Remove(dest); err != nil {
+		t.Fatal(err)
+	}
+	if resolved := dir.plannedDest(linkSource{"/dots/foo", "foo"}, dest); resolved != expected[0].dest {
+		t.Errorf("expected planned dest %s to be reused, got %s", expected[0].dest, resolved)
+	}
+}
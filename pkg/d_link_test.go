@@ -0,0 +1,33 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"olympos.io/encoding/edn"
+)
+
+func TestLink_ModeIsAppliedThroughSymlinkToSrc(t *testing.T) {
+	tmp := t.TempDir()
+	src, dest := JoinPath(tmp, "id_ed25519"), JoinPath(tmp, "home", "id_ed25519")
+	if err := ioutil.WriteFile(src, []byte("key"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, ok := (&linkDirective{src: []string{src}, dest: []string{dest}}).init(CreateContext(), map[Any]Any{edn.Keyword("mode"): "600"})
+	if !ok {
+		t.Fatal("expected link with a mode to be created")
+	}
+	for i := 0; i < 2; i++ {
+		// once when linking and again when dest is already linked.
+		os.Chmod(src, 0644)
+		dir.Run()
+		if info, err := os.Lstat(dest); err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Fatalf("expected dest to be a symlink, got %v (%v)", info, err)
+		}
+		if info, err := os.Stat(src); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("expected mode to be applied to src through the link, got %v (%v)", info, err)
+		}
+	}
+}
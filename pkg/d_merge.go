@@ -0,0 +1,307 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"olympos.io/encoding/edn"
+)
+
+// strategies for merging lists found in both src and dest.
+const (
+	mergeArraysReplace = "replace"
+	mergeArraysAppend  = "append"
+	mergeArraysPrepend = "prepend"
+)
+
+/**
+ * A directive to deep merge the keys from a structured config file in your
+ * dotfiles into an existing file, for programs that keep your settings
+ * alongside state they manage themselves.
+ *
+ * Keys in dest that aren't in src are left alone, maps found in both are
+ * merged recursively and every other value in src replaces the one in
+ * dest. Lists are replaced unless arrays says to add any missing items to
+ * the existing list instead.
+ */
+type mergeDirective struct {
+	src  string
+	dest string
+
+	// the formats of src and dest, guessed from their extensions.
+	srcFormat  string
+	destFormat string
+
+	// how to merge lists in src with lists in dest.
+	arrays string
+
+	// paths of keys that should be removed from dest.
+	remove [][]string
+
+	// write dest as root.
+	sudo bool
+}
+
+// a single key that's changed in dest.
+type mergeChange struct {
+	action string
+	key    []string
+}
+
+func (change mergeChange) String() string {
+	return fmt.Sprintf("%s %s", change.action, strings.Join(change.key, "."))
+}
+
+func dMerge(ctx *Context, args AnySlice) {
+	dSrcDestPairs(ctx, args, "merge", func(opts map[Any]Any, src, dest string) {
+		if dir, ok := (&mergeDirective{src: src, dest: dest}).init(ctx, opts); ok {
+			ctx.DirChan <- dir
+		}
+	})
+}
+
+func (dir *mergeDirective) init(ctx *Context, opts map[Any]Any) (*mergeDirective, bool) {
+	var format string
+	ok := readMapOptionString(ctx.mergeOpts, opts, &format, "format", "")
+	ok = readMapOptionString(ctx.mergeOpts, opts, &dir.arrays, "arrays", mergeArraysReplace) && ok
+	ok = readMapOptionMergeKeys(ctx.mergeOpts, opts, &dir.remove, "remove") && ok
+	ok = readMapOptionBool(ctx.mergeOpts, opts, &dir.sudo, "sudo", false) && ok
+	if !ok {
+		return dir, false
+	}
+
+	switch dir.arrays {
+	case mergeArraysReplace, mergeArraysAppend, mergeArraysPrepend:
+	default:
+		log.Error().Str("arrays", dir.arrays).
+			Msg("arrays must be one of replace, append or prepend")
+		return dir, false
+	}
+
+	dir.destFormat = format
+	if dir.destFormat == "" {
+		if dir.destFormat, ok = formatFromPath(dir.dest); !ok {
+			log.Error().Str("dest", dir.dest).
+				Msgf("Unable to guess format of merge dest, please specify a %s", edn.Keyword("format"))
+			return dir, false
+		}
+	}
+	// src is read in the same format as dest unless it looks otherwise.
+	if dir.srcFormat, ok = formatFromPath(dir.src); !ok {
+		dir.srcFormat = dir.destFormat
+	}
+	return dir, true
+}
+
+/**
+ * Read the paths of keys that should be removed. Each path is either a
+ * string, for a key at the top level, or a list of strings for a nested
+ * key. We don't split strings on dots since plenty of programs (VS Code
+ * for example) have dots in their keys.
+ */
+func readMapOptionMergeKeys(ctxOpts map[string]Any, opts map[Any]Any, field *[][]string, name string) bool {
+	opt, ok := ctxOpts[name]
+	if optVal, optOk := opts[edn.Keyword(name)]; optOk {
+		opt = optVal
+		ok = true
+	}
+	if !ok {
+		return true
+	}
+
+	keys, ok := opt.(AnySlice)
+	if !ok {
+		keys = AnySlice{opt}
+	}
+	for _, key := range keys {
+		var path []string
+		if !readMapOptionStrings(nil, map[Any]Any{edn.Keyword(name): key}, &path, name, nil) {
+			return false
+		}
+		*field = append(*field, path)
+	}
+	return true
+}
+
+func (dir *mergeDirective) Log() string {
+	var flags string
+	if dir.sudo {
+		flags += "sudo "
+	}
+	if dir.arrays != mergeArraysReplace {
+		flags += fmt.Sprintf("--arrays %s ", dir.arrays)
+	}
+	res := fmt.Sprintf("merge %s%s %s", flags, dir.src, dir.dest)
+
+	// show exactly what would change, so inspecting acts as a dry run.
+	if _, _, changes, err := dir.plan(); err == nil {
+		for _, change := range changes {
+			res += "\n  " + change.String()
+		}
+	}
+	return res
+}
+
+func (dir *mergeDirective) Run() {
+	before, after, changes, err := dir.plan()
+	if err != nil {
+		log.Error().Str("src", dir.src).
+			Str("dest", dir.dest).
+			Str("error", err.Error()).
+			Msg("Failed to merge into file")
+		return
+	}
+	if len(changes) == 0 {
+		log.Debug().Str("src", dir.src).
+			Str("dest", dir.dest).
+			Msg("Skipping merge because dest is up to date")
+		return
+	}
+
+	for _, change := range changes {
+		log.Info().Str("action", change.action).
+			Str("key", strings.Join(change.key, ".")).
+			Str("dest", dir.dest).
+			Msg("Merging key")
+	}
+	if losses := formatLosses(dir.destFormat, []byte(before)); len(losses) > 0 {
+		log.Warn().Str("dest", dir.dest).
+			Msgf("Merge dest will lose its %s", strings.Join(losses, " and "))
+	}
+
+	ops := fileOps{dir.sudo}
+	if exists, _ := pathExists(fp.Dir(dir.dest), true); !exists {
+		if err := ops.mkdirAll(fp.Dir(dir.dest), 0744); err != nil {
+			log.Error().Str("dest", dir.dest).
+				Str("error", err.Error()).
+				Msg("Failed to create parent directory of merge dest")
+			return
+		}
+	}
+	if err := ops.writeFile(dir.dest, []byte(after), editFileMode); err != nil {
+		log.Error().Str("dest", dir.dest).
+			Str("error", err.Error()).
+			Msg("Failed to write merge dest")
+	}
+}
+
+func (dir *mergeDirective) Diff(w io.Writer) {
+	if before, after, changes, err := dir.plan(); err == nil && len(changes) != 0 {
+		fmt.Fprint(w, unifiedDiff(dir.dest, dir.dest, before, after))
+	}
+}
+
+// work out the contents of dest before and after merging in src.
+func (dir *mergeDirective) plan() (string, string, []mergeChange, error) {
+	srcBytes, err := ioutil.ReadFile(dir.src)
+	if err != nil {
+		return "", "", nil, err
+	}
+	src, err := decodeFormat(dir.srcFormat, srcBytes)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to parse %s: %s", dir.src, err)
+	}
+
+	destBytes, err := ioutil.ReadFile(dir.dest)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", nil, err
+	}
+	dest, err := decodeFormat(dir.destFormat, destBytes)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to parse %s: %s", dir.dest, err)
+	}
+
+	changes := mergeMaps(dest, src, nil, dir.arrays)
+	for _, key := range dir.remove {
+		if removeMergeKey(dest, key) {
+			changes = append(changes, mergeChange{"remove", key})
+		}
+	}
+	if len(changes) == 0 {
+		return string(destBytes), string(destBytes), changes, nil
+	}
+
+	after, err := encodeFormat(dir.destFormat, dest, destBytes)
+	return string(destBytes), string(after), changes, err
+}
+
+// deep merge src into dest, returning every key in dest that was changed.
+func mergeMaps(dest, src *orderedMap, path []string, arrays string) []mergeChange {
+	changes := make([]mergeChange, 0)
+	for _, key := range src.keys {
+		keyPath := append(append([]string{}, path...), key)
+		srcVal := src.values[key]
+		destVal, exists := dest.get(key)
+		if !exists {
+			dest.set(key, srcVal)
+			changes = append(changes, mergeChange{"add", keyPath})
+			continue
+		}
+
+		srcMap, srcIsMap := srcVal.(*orderedMap)
+		destMap, destIsMap := destVal.(*orderedMap)
+		if srcIsMap && destIsMap {
+			changes = append(changes, mergeMaps(destMap, srcMap, keyPath, arrays)...)
+			continue
+		}
+
+		srcArr, srcIsArr := srcVal.([]interface{})
+		destArr, destIsArr := destVal.([]interface{})
+		if srcIsArr && destIsArr {
+			srcVal = mergeArrays(destArr, srcArr, arrays)
+		}
+		if !reflect.DeepEqual(srcVal, destVal) {
+			dest.set(key, srcVal)
+			changes = append(changes, mergeChange{"change", keyPath})
+		}
+	}
+	return changes
+}
+
+// merge the list src into dest. the items from src that aren't already in
+// dest are added to it so merging again doesn't add them twice.
+func mergeArrays(dest, src []interface{}, arrays string) []interface{} {
+	if arrays == mergeArraysReplace {
+		return src
+	}
+
+	missing := make([]interface{}, 0, len(src))
+	for _, item := range src {
+		found := false
+		for _, existing := range dest {
+			if reflect.DeepEqual(item, existing) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, item)
+		}
+	}
+
+	if arrays == mergeArraysPrepend {
+		return append(missing, dest...)
+	}
+	return append(append([]interface{}{}, dest...), missing...)
+}
+
+// remove the key at path from tree, returning whether it existed.
+func removeMergeKey(tree *orderedMap, path []string) bool {
+	for i, key := range path {
+		if i == len(path)-1 {
+			return tree.remove(key)
+		}
+		val, _ := tree.get(key)
+		var ok bool
+		if tree, ok = val.(*orderedMap); !ok {
+			return false
+		}
+	}
+	return false
+}
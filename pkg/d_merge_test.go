@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func mustDecodeJSON(t *testing.T, data string) *orderedMap {
+	tree, err := decodeFormat(formatJSON, []byte(data))
+	if err != nil {
+		t.Fatalf("failed to decode json: %s", err)
+	}
+	return tree
+}
+
+func TestMergeMaps_DeepMergesKeys(t *testing.T) {
+	dest := mustDecodeJSON(t, `{"a": 1, "b": {"c": 1, "d": 1}}`)
+	src := mustDecodeJSON(t, `{"b": {"c": 2, "e": 2}, "f": 2}`)
+	changes := mergeMaps(dest, src, nil, mergeArraysReplace)
+
+	expected := mustDecodeJSON(t, `{"a": 1, "b": {"c": 2, "d": 1, "e": 2}, "f": 2}`)
+	if !reflect.DeepEqual(dest, expected) {
+		t.Errorf("unexpected merge result %s", encodeJSON(dest, " "))
+	}
+	if len(changes) != 3 {
+		t.Errorf("expected 3 changes, got %v", changes)
+	}
+	if len(mergeMaps(dest, src, nil, mergeArraysReplace)) != 0 {
+		t.Error("merging again reported changes")
+	}
+}
+
+func TestMergeArrays_OnlyAddsMissingItems(t *testing.T) {
+	dest := []interface{}{"a", "b"}
+	src := []interface{}{"b", "c"}
+	if res := mergeArrays(dest, src, mergeArraysAppend); !reflect.DeepEqual(res, []interface{}{"a", "b", "c"}) {
+		t.Errorf("unexpected appended list %v", res)
+	}
+	if res := mergeArrays(dest, src, mergeArraysPrepend); !reflect.DeepEqual(res, []interface{}{"c", "a", "b"}) {
+		t.Errorf("unexpected prepended list %v", res)
+	}
+	if res := mergeArrays(dest, src, mergeArraysReplace); !reflect.DeepEqual(res, src) {
+		t.Errorf("unexpected replaced list %v", res)
+	}
+}
+
+func TestRemoveMergeKey_RemovesNestedKeys(t *testing.T) {
+	tree := mustDecodeJSON(t, `{"a.b": 1, "c": {"d": 1}}`)
+	if !removeMergeKey(tree, []string{"a.b"}) || !removeMergeKey(tree, []string{"c", "d"}) {
+		t.Error("failed to remove existing keys")
+	}
+	if removeMergeKey(tree, []string{"c", "d", "e"}) {
+		t.Error("removed key that doesn't exist")
+	}
+	if !reflect.DeepEqual(tree, mustDecodeJSON(t, `{"c": {}}`)) {
+		t.Errorf("unexpected tree after removal %s", encodeJSON(tree, " "))
+	}
+}
@@ -2,8 +2,8 @@ package pkg
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"strconv"
 
 	"github.com/rs/zerolog/log"
 	"olympos.io/encoding/edn"
@@ -16,6 +16,13 @@ type mkdirDirective struct {
 
 	// file permissions for the directory
 	chmod os.FileMode
+
+	// whether chmod was given explicitly, in which case the permissions
+	// of existing directories are also updated to match it.
+	reconcile bool
+
+	// create the directory as root.
+	sudo bool
 }
 
 func dMkdir(ctx *Context, args AnySlice) {
@@ -35,32 +42,36 @@ func dMkdir(ctx *Context, args AnySlice) {
 				return ctx, false
 			}
 
-			if perms, ok := opts[edn.Keyword("chmod")]; ok {
-				if permInt, err := strconv.ParseInt(fmt.Sprintf("%v", perms), 8, 64); err == nil {
-					ctx.mkdirOpts["permissions"] = os.FileMode(permInt)
-				} else {
-					log.Warn().Str("permissions", fmt.Sprintf("%v", perms)).
-						Msgf("Permissions must be a valid file permission flag, not %T", perms)
+			// :mode is accepted as an alias for :chmod to match other directives.
+			for _, opt := range []string{"mode", "chmod"} {
+				if perms, ok := opts[edn.Keyword(opt)]; ok {
+					ctx.mkdirOpts["chmod"] = perms
 				}
 			}
+			if sudo, ok := opts[edn.Keyword("sudo")]; ok {
+				ctx.mkdirOpts["sudo"] = sudo
+			}
 			return ctx, true
 		},
 	)
 }
 
 func (dir *mkdirDirective) init(ctx *Context) *mkdirDirective {
-	if dirPerms, ok := ctx.mkdirOpts["permissions"]; ok {
-		dir.chmod = dirPerms.(os.FileMode)
-	} else {
-		// TODO get default permissions from fs
-		dir.chmod = os.FileMode(0744)
+	_, dir.reconcile = ctx.mkdirOpts["chmod"]
+	// TODO get default permissions from fs
+	if !readMapOptionMode(ctx.mkdirOpts, nil, &dir.chmod, "chmod", 0744) {
+		dir.reconcile = false
 	}
+	readMapOptionBool(ctx.mkdirOpts, nil, &dir.sudo, "sudo", false)
 
 	return dir
 }
 
 func (dir *mkdirDirective) Log() string {
-	return fmt.Sprintf("mkdir %d %v", dir.chmod, dir.path)
+	if dir.sudo {
+		return fmt.Sprintf("sudo mkdir %s %v", formatMode(dir.chmod), dir.path)
+	}
+	return fmt.Sprintf("mkdir %s %v", formatMode(dir.chmod), dir.path)
 }
 
 func (dir *mkdirDirective) Run() {
@@ -72,17 +83,35 @@ func (dir *mkdirDirective) Run() {
 	} else if exists {
 		log.Debug().Str("path", dir.path).
 			Msg("Skipping creating directory because path exists")
+		if dir.reconcile {
+			reconcileMode(fileOps{dir.sudo}, dir.path, dir.chmod)
+		}
 		return
 	}
 
 	log.Info().Str("path", dir.path).
 		Int("permissions", int(dir.chmod)).
 		Msg("Creating directory")
-	err := os.MkdirAll(dir.path, dir.chmod)
+	err := fileOps{dir.sudo}.mkdirAll(dir.path, dir.chmod)
 	if err != nil {
 		log.Error().Str("path", dir.path).
 			Int("permissions", int(dir.chmod)).
 			Str("error", err.Error()).
 			Msg("Failed to create directory")
+	} else if dir.reconcile {
+		// the permissions given to MkdirAll are masked by the umask.
+		reconcileMode(fileOps{dir.sudo}, dir.path, dir.chmod)
+	}
+}
+
+// report when the permissions of an existing directory have drifted.
+func (dir *mkdirDirective) Diff(w io.Writer) {
+	if !dir.reconcile {
+		return
+	}
+	if info, err := os.Stat(dir.path); err == nil {
+		if current := info.Mode() & chmodBits; current != dir.chmod {
+			fmt.Fprintf(w, "mode %s => %s %s\n", formatMode(current), formatMode(dir.chmod), dir.path)
+		}
 	}
 }
@@ -0,0 +1,117 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	fp "path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"olympos.io/encoding/edn"
+)
+
+/**
+ * A directive to apply a unified diff from your dotfiles to a file you don't
+ * own, such as a config file provided by a vendor.
+ *
+ * A patch that's already been applied (because reversing it succeeds) is
+ * skipped, so running it again does nothing. When any hunk of the patch
+ * can't be found the file is left untouched.
+ */
+type patchDirective struct {
+	// the file being patched.
+	file string
+
+	// the path to the unified diff applied to file.
+	diff string
+
+	// patch file as root.
+	sudo bool
+}
+
+func dPatch(ctx *Context, args AnySlice) {
+	dPathValuePairs(ctx, args, "file", "diff", func(file string, opts map[Any]Any) (directive, bool) {
+		return (&patchDirective{file: file}).init(ctx, opts)
+	})
+}
+
+func (dir *patchDirective) init(ctx *Context, opts map[Any]Any) (*patchDirective, bool) {
+	ok := readMapOptionBool(ctx.patchOpts, opts, &dir.sudo, "sudo", false)
+
+	diff, isStr := opts[edn.Keyword("diff")].(string)
+	if !isStr {
+		log.Error().Str("file", dir.file).
+			Msgf("%s directive must specify the path to a %s", edn.Keyword("patch"), edn.Keyword("diff"))
+		return dir, false
+	}
+	diff, evalOk := ctx.eval(diff)
+	dir.diff = ExpandTilde(ctx.Home, JoinPath(ctx.Cwd, fp.FromSlash(diff)))
+	return dir, ok && evalOk
+}
+
+func (dir *patchDirective) Log() string {
+	var flags string
+	if dir.sudo {
+		flags += "sudo "
+	}
+	return fmt.Sprintf("patch %s%s < %s", flags, dir.file, dir.diff)
+}
+
+// read the hunks from the diff, alongside whether they've already been
+// applied to the file.
+func (dir *patchDirective) hunks() ([]patchHunk, bool, error) {
+	diff, err := ioutil.ReadFile(dir.diff)
+	if err != nil {
+		return nil, false, err
+	}
+	hunks, err := parsePatch(string(diff))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse %s: %s", dir.diff, err)
+	}
+
+	content, err := ioutil.ReadFile(dir.file)
+	if err != nil {
+		return nil, false, err
+	}
+	lines, _ := splitFileLines(string(content))
+	_, err = applyPatch(lines, reversePatch(hunks))
+	return hunks, err == nil, nil
+}
+
+func (dir *patchDirective) Run() {
+	hunks, applied, err := dir.hunks()
+	if err != nil {
+		log.Error().Str("file", dir.file).
+			Str("diff", dir.diff).
+			Str("error", err.Error()).
+			Msg("Failed to read patch")
+		return
+	}
+	if applied {
+		log.Debug().Str("file", dir.file).
+			Str("diff", dir.diff).
+			Msg("Skipping patch because it's already been applied")
+		return
+	}
+
+	log.Info().Str("file", dir.file).
+		Str("diff", dir.diff).
+		Msg("Patching file")
+	_, err = editFile(fileOps{dir.sudo}, dir.file, false, func(lines []string) ([]string, error) {
+		return applyPatch(lines, hunks)
+	})
+	if err != nil {
+		log.Error().Str("file", dir.file).
+			Str("diff", dir.diff).
+			Str("error", err.Error()).
+			Msg("Failed to apply patch, file left unchanged")
+	}
+}
+
+func (dir *patchDirective) Diff(w io.Writer) {
+	if hunks, applied, err := dir.hunks(); err == nil && !applied {
+		diffEditFile(w, dir.file, func(lines []string) ([]string, error) {
+			return applyPatch(lines, hunks)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package pkg
+
+import (
+	fp "path/filepath"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"olympos.io/encoding/edn"
+)
+
+/**
+ * A machine profile, such as work or home, declared in the environment file.
+ *
+ * A profile is activated either explicitly (with --profile) or because the
+ * hostname of the current machine matches one of its hostnames. Activating
+ * a profile applies its defs, installs its bots (unless bots were given on
+ * the command line) and imports its imports after the root config.
+ */
+type profile struct {
+	// glob patterns for the hostnames this profile is automatically used on.
+	hostnames []string
+
+	// arguments for :def applied when the profile is activated.
+	defs AnySlice
+
+	bots    []string
+	imports []string
+}
+
+// declare a profile from the environment file, in the form:
+//
+//	(:profile "work" {:hostname "work-*" :bots "work" :import "work" :def ("FOO" "bar")})
+func dDefProfile(ctx *Context, args AnySlice) {
+	if len(args) != 2 {
+		log.Error().Interface("args", args).
+			Msgf("%s must be given a name and a map of options", edn.Keyword("profile"))
+		return
+	}
+	name, nameOk := args[0].(string)
+	opts, optsOk := args[1].(map[Any]Any)
+	if !nameOk || !optsOk {
+		log.Error().Interface("args", args).
+			Msgf("%s must be given a name and a map of options", edn.Keyword("profile"))
+		return
+	}
+	if ctx.profilesActivated {
+		log.Warn().Str("profile", name).
+			Msg("Profiles must be declared in your environment file, ignoring profile")
+		return
+	}
+
+	prof := &profile{}
+	ok := readMapOptionStrings(nil, opts, &prof.hostnames, "hostname", nil)
+	ok = readMapOptionStrings(nil, opts, &prof.bots, "bots", nil) && ok
+	ok = readMapOptionStrings(nil, opts, &prof.imports, "import", nil) && ok
+	if defs, defsOk := opts[edn.Keyword("def")]; defsOk {
+		if prof.defs, defsOk = defs.(AnySlice); !defsOk {
+			log.Error().Str("profile", name).
+				Msgf("%s of a profile must be a list, not %T", edn.Keyword("def"), defs)
+			ok = false
+		}
+	}
+	if !ok {
+		return
+	}
+
+	log.Debug().Str("profile", name).
+		Msg("Declaring profile")
+	ctx.declaredProfiles[name] = prof
+}
+
+/**
+ * Activate the profiles in names, or when there aren't any every declared
+ * profile whose hostnames match the current machine. This should be called
+ * once, after the environment file has been loaded.
+ */
+func (ctx *Context) ActivateProfiles(names []string) {
+	ctx.profilesActivated = true
+	explicit := len(names) > 0
+	if !explicit {
+		names = ctx.profilesForHost()
+	}
+
+	defaultBots := len(ctx.Bots) == 0
+	for _, name := range names {
+		if StringSliceContains(ctx.Profiles, name) {
+			continue
+		}
+		log.Info().Str("profile", name).
+			Msg("Activating profile")
+		ctx.Profiles = append(ctx.Profiles, name)
+
+		prof, ok := ctx.declaredProfiles[name]
+		if !ok {
+			// profiles can be used in conditions without being declared, but
+			// one that was asked for could just as well be a typo.
+			if explicit {
+				log.Warn().Str("profile", name).
+					Msg("Profile isn't declared in your environment file, only conditions will use it")
+			}
+			continue
+		}
+		if len(prof.defs) > 0 {
+			dDef(ctx, prof.defs)
+		}
+		if defaultBots {
+			for _, bot := range prof.bots {
+				if !StringSliceContains(ctx.profileBots, bot) {
+					ctx.profileBots = append(ctx.profileBots, bot)
+				}
+			}
+		}
+	}
+}
+
+// the names of the declared profiles that match the current hostname.
+func (ctx *Context) profilesForHost() []string {
+	hostname, err := dConditionHostnameFunc()
+	if err != nil {
+		log.Warn().Str("error", err.Error()).
+			Msg("Failed to determine hostname to select profiles")
+		return nil
+	}
+
+	var res []string
+	for name, prof := range ctx.declaredProfiles {
+		for _, pattern := range prof.hostnames {
+			if ok, _ := fp.Match(pattern, hostname); ok {
+				res = append(res, name)
+				break
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+/**
+ * The configs to import when starting dotty, the root config followed by the
+ * imports of every active profile.
+ */
+func (ctx *Context) ImportRoots() AnySlice {
+	roots := AnySlice{"config"}
+	for _, name := range ctx.Profiles {
+		if prof, ok := ctx.declaredProfiles[name]; ok {
+			for _, imp := range prof.imports {
+				roots = append(roots, imp)
+			}
+		}
+	}
+	return roots
+}
+
+// passes when any of the given profiles are active.
+func dConditionProfile(ctx *Context, args AnySlice) bool {
+	names, ok := dConditionStrings(ctx, args, "profile")
+	if !ok {
+		return false
+	}
+	for _, name := range names {
+		if StringSliceContains(ctx.Profiles, name) {
+			return true
+		}
+	}
+	return false
+}
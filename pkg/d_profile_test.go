@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testProfiles = `(
+  (:profile "work" {:hostname ["work-*" "*.corp"]
+                    :bots ["ssh" "vpn"]
+                    :import "work"
+                    :def ("EMAIL" "me@work.com")})
+  (:profile "home" {:hostname "home-*" :def ("EMAIL" "me@home.com")})
+)`
+
+func testProfileContext(hostname string, bots ...string) *Context {
+	dConditionHostnameFunc = func() (string, error) { return hostname, nil }
+	ctx := CreateContext()
+	ctx.Bots = append(ctx.Bots, bots...)
+	dDef(ctx, pathsFromEdn(testProfiles))
+	return ctx
+}
+
+func TestActivateProfiles_SelectsProfileByHostname(t *testing.T) {
+	defer func() { dConditionHostnameFunc = os.Hostname }()
+
+	ctx := testProfileContext("work-laptop")
+	ctx.ActivateProfiles(nil)
+	if !reflect.DeepEqual(ctx.Profiles, []string{"work"}) {
+		t.Errorf("expected work profile to be active, got %v", ctx.Profiles)
+	}
+	if email := ctx.envOpts["EMAIL"]; email != "me@work.com" {
+		t.Errorf("expected profile defs to be applied, got EMAIL=%q", email)
+	}
+	if !ctx.installingBot("ssh") || !ctx.installingBot("vpn") {
+		t.Errorf("expected profile bots to be installed, got %v", ctx.profileBots)
+	}
+	if len(ctx.Bots) != 0 {
+		t.Errorf("expected profile bots to be kept apart from saved bots, got %v", ctx.Bots)
+	}
+	if roots := ctx.ImportRoots(); !reflect.DeepEqual(roots, AnySlice{"config", "work"}) {
+		t.Errorf("expected profile import to follow config, got %v", roots)
+	}
+	if !testCondition(t, ctx, `(:profile "home" "work")`) || testCondition(t, ctx, `(:profile "home")`) {
+		t.Error("profile predicate doesn't match the active profiles")
+	}
+}
+
+func TestActivateProfiles_PrefersExplicitProfiles(t *testing.T) {
+	defer func() { dConditionHostnameFunc = os.Hostname }()
+
+	ctx := testProfileContext("work-laptop", "git")
+	warnings := testCaptureWarnings(t)
+	ctx.ActivateProfiles([]string{"home", "travel"})
+	if !reflect.DeepEqual(ctx.Profiles, []string{"home", "travel"}) {
+		t.Errorf("expected only the given profiles to be active, got %v", ctx.Profiles)
+	}
+	if email := ctx.envOpts["EMAIL"]; email != "me@home.com" {
+		t.Errorf("expected home profile defs to be applied, got EMAIL=%q", email)
+	}
+	if !reflect.DeepEqual(ctx.Bots, []string{"git"}) || len(ctx.profileBots) != 0 {
+		t.Errorf("expected bots given explicitly to be kept, got %v", ctx.Bots)
+	}
+	if !strings.Contains(warnings.String(), `"profile":"travel"`) || strings.Contains(warnings.String(), `"profile":"home"`) {
+		t.Errorf("expected only the undeclared profile to be warned about, got %q", warnings.String())
+	}
+}
+
+func TestActivateProfiles_NoMatchingHostname(t *testing.T) {
+	defer func() { dConditionHostnameFunc = os.Hostname }()
+
+	ctx := testProfileContext("server")
+	warnings := testCaptureWarnings(t)
+	ctx.ActivateProfiles(nil)
+	if len(ctx.Profiles) != 0 {
+		t.Errorf("expected no profiles to be active, got %v", ctx.Profiles)
+	}
+	if roots := ctx.ImportRoots(); !reflect.DeepEqual(roots, AnySlice{"config"}) {
+		t.Errorf("expected only the root config to be imported, got %v", roots)
+	}
+	if warnings.Len() != 0 {
+		t.Errorf("expected profiles selected by hostname to be silent, got %q", warnings.String())
+	}
+}
@@ -0,0 +1,281 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+/**
+ * A directive to mirror the directory src into dest.
+ *
+ * Files missing from dest are copied across and files that've changed are
+ * updated. A file has changed when its size or modification time differs
+ * from the source, or when checksum is true when its contents differ. With
+ * delete any files in dest that aren't in src are removed.
+ */
+type syncDirective struct {
+	src  string
+	dest string
+
+	// remove files from dest that don't exist in src.
+	delete bool
+
+	// glob patterns for files (relative to src or dest) that are left alone.
+	exclude []string
+
+	// compare file contents, instead of their size and modification time.
+	checksum bool
+}
+
+// the kinds of change a sync can make.
+const (
+	syncMkdir  = "mkdir"
+	syncCopy   = "copy"
+	syncUpdate = "update"
+	syncDelete = "delete"
+)
+
+// a single change needed to bring dest in sync with src.
+type syncChange struct {
+	action string
+
+	// the path of the changed file relative to src and dest.
+	path string
+}
+
+func dSync(ctx *Context, args AnySlice) {
+	dSrcDestPairs(ctx, args, "sync", func(opts map[Any]Any, src, dest string) {
+		ctx.DirChan <- (&syncDirective{src: src, dest: dest}).init(ctx, opts)
+	})
+}
+
+func (dir *syncDirective) init(ctx *Context, opts map[Any]Any) *syncDirective {
+	readMapOptionBool(ctx.syncOpts, opts, &dir.delete, "delete", false)
+	readMapOptionStrings(ctx.syncOpts, opts, &dir.exclude, "exclude", nil)
+	readMapOptionBool(ctx.syncOpts, opts, &dir.checksum, "checksum", false)
+	return dir
+}
+
+func (dir *syncDirective) Log() string {
+	var flags string
+	if dir.delete {
+		flags += "--delete "
+	}
+	if dir.checksum {
+		flags += "--checksum "
+	}
+	res := fmt.Sprintf("sync %s%s %s", flags, dir.src, dir.dest)
+
+	// show exactly what would change, so inspecting acts as a dry run.
+	if changes, err := dir.plan(); err == nil {
+		for _, change := range changes {
+			res += fmt.Sprintf("\n  %s %s", change.action, change.path)
+		}
+	}
+	return res
+}
+
+func (dir *syncDirective) Run() {
+	changes, err := dir.plan()
+	if err != nil {
+		log.Error().Str("src", dir.src).
+			Str("dest", dir.dest).
+			Str("error", err.Error()).
+			Msg("Failed to compare src and dest")
+		return
+	}
+	if len(changes) == 0 {
+		log.Debug().Str("src", dir.src).
+			Str("dest", dir.dest).
+			Msg("Skipping sync because dest is up to date")
+		return
+	}
+
+	// changes are relative to dest, so it has to exist before they're made.
+	if err := dir.mkdir(dir.src, dir.dest); err != nil {
+		log.Error().Str("src", dir.src).
+			Str("dest", dir.dest).
+			Str("error", err.Error()).
+			Msg("Failed to create sync dest")
+		return
+	}
+
+	for _, change := range changes {
+		src, dest := JoinPath(dir.src, change.path), JoinPath(dir.dest, change.path)
+		log.Info().Str("action", change.action).
+			Str("src", src).
+			Str("dest", dest).
+			Msg("Syncing file")
+
+		var err error
+		switch change.action {
+		case syncMkdir:
+			err = dir.mkdir(src, dest)
+		case syncCopy, syncUpdate:
+			err = dir.copy(src, dest)
+		case syncDelete:
+			err = os.RemoveAll(dest)
+		}
+		if err != nil {
+			log.Error().Str("action", change.action).
+				Str("src", src).
+				Str("dest", dest).
+				Str("error", err.Error()).
+				Msg("Failed to sync file")
+		}
+	}
+}
+
+func (dir *syncDirective) Diff(w io.Writer) {
+	changes, err := dir.plan()
+	if err != nil {
+		return
+	}
+	for _, change := range changes {
+		src, dest := JoinPath(dir.src, change.path), JoinPath(dir.dest, change.path)
+		switch change.action {
+		case syncUpdate:
+			destBytes, destErr := ioutil.ReadFile(dest)
+			srcBytes, srcErr := ioutil.ReadFile(src)
+			if destErr == nil && srcErr == nil {
+				fmt.Fprint(w, unifiedDiff(dest, src, string(destBytes), string(srcBytes)))
+			}
+		case syncCopy:
+			fmt.Fprintf(w, "Only in %s: %s\n", dir.src, change.path)
+		case syncDelete:
+			fmt.Fprintf(w, "Only in %s: %s\n", dir.dest, change.path)
+		}
+	}
+}
+
+// create the directory dest with the same permissions as src.
+func (dir *syncDirective) mkdir(src, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(dest, info.Mode().Perm())
+}
+
+// copy the file src to dest, keeping its modification time so it's seen
+// as unchanged on the next run.
+func (dir *syncDirective) copy(src, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	// make sure we're replacing dest, not writing through a link at it.
+	if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := copyFile(src, dest); err != nil {
+		return err
+	}
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
+
+// work out every change needed to bring dest in sync with src.
+func (dir *syncDirective) plan() ([]syncChange, error) {
+	srcInfo, err := os.Stat(dir.src)
+	if err != nil {
+		return nil, err
+	}
+	if !srcInfo.IsDir() {
+		return nil, fmt.Errorf("sync src must be a directory")
+	}
+
+	changes := make([]syncChange, 0)
+	seen := make(map[string]bool)
+	err = fp.Walk(dir.src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := fp.Rel(dir.src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if globMatchesAny(dir.exclude, rel) {
+			if info.IsDir() {
+				return fp.SkipDir
+			}
+			return nil
+		}
+		seen[rel] = true
+
+		dest := JoinPath(dir.dest, rel)
+		destInfo, err := os.Stat(dest)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if info.IsDir() {
+			if destInfo == nil {
+				changes = append(changes, syncChange{syncMkdir, rel})
+			} else if !destInfo.IsDir() {
+				changes = append(changes, syncChange{syncDelete, rel}, syncChange{syncMkdir, rel})
+			}
+			return nil
+		}
+
+		if destInfo == nil {
+			changes = append(changes, syncChange{syncCopy, rel})
+		} else if destInfo.IsDir() {
+			changes = append(changes, syncChange{syncDelete, rel}, syncChange{syncCopy, rel})
+		} else if changed, err := dir.changed(path, info, dest, destInfo); err != nil {
+			return err
+		} else if changed {
+			changes = append(changes, syncChange{syncUpdate, rel})
+		}
+		return nil
+	})
+	if err != nil || !dir.delete {
+		return changes, err
+	}
+
+	deletions := make([]syncChange, 0)
+	err = fp.Walk(dir.dest, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, err := fp.Rel(dir.dest, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if globMatchesAny(dir.exclude, rel) || seen[rel] {
+			return nil
+		}
+
+		deletions = append(deletions, syncChange{syncDelete, rel})
+		if info.IsDir() {
+			// everything in here is removed alongside it.
+			return fp.SkipDir
+		}
+		return nil
+	})
+	sort.Slice(deletions, func(i, j int) bool {
+		return strings.Compare(deletions[i].path, deletions[j].path) < 0
+	})
+	return append(changes, deletions...), err
+}
+
+// assert whether the file dest differs from src.
+func (dir *syncDirective) changed(src string, srcInfo os.FileInfo, dest string, destInfo os.FileInfo) (bool, error) {
+	if srcInfo.Size() != destInfo.Size() {
+		return true, nil
+	}
+	if !dir.checksum {
+		return !srcInfo.ModTime().Equal(destInfo.ModTime()), nil
+	}
+	same, err := filesEqual(src, dest)
+	return !same, err
+}
@@ -0,0 +1,40 @@
+package pkg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSync_CreatesMissingDest(t *testing.T) {
+	tmp := t.TempDir()
+	src, dest := fp.Join(tmp, "src"), fp.Join(tmp, "missing", "dest")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo", "bar"} {
+		if err := ioutil.WriteFile(fp.Join(src, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := &syncDirective{src: src, dest: dest}
+	var diff bytes.Buffer
+	dir.Diff(&diff)
+	for _, name := range []string{"foo", "bar"} {
+		if !strings.Contains(diff.String(), "Only in "+src+": "+name) {
+			t.Errorf("expected diff to show new file %s, got %q", name, diff.String())
+		}
+	}
+
+	dir.Run()
+	for _, name := range []string{"foo", "bar"} {
+		assertTestFile(t, fp.Join(dest, name), name)
+	}
+	if changes, err := dir.plan(); err != nil || len(changes) != 0 {
+		t.Errorf("expected dest to be in sync, got %v (%v)", changes, err)
+	}
+}
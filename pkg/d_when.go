@@ -14,6 +14,66 @@ func dWhen(ctx *Context, args AnySlice) {
 	}
 }
 
+/**
+ * Choose between two branches with a single condition. Each branch is either
+ * one directive or a list of directives, and the else branch can optionally
+ * be preceded by :else.
+ *   (:if CONDITION (:link ...) :else ((:link ...) (:mkdir ...)))
+ */
+func dIf(ctx *Context, args AnySlice) {
+	if len(args) == 4 && args[2] == edn.Keyword("else") {
+		args = AnySlice{args[0], args[1], args[3]}
+	}
+	if len(args) < 2 || len(args) > 3 {
+		log.Error().Interface("args", args).
+			Msgf("%s directive must be given a condition, a body and optionally an else body", edn.Keyword("if"))
+		return
+	}
+
+	if dCondition(ctx, args[0]) {
+		dispatchBranch(ctx, args[1], "if")
+	} else if len(args) == 3 {
+		dispatchBranch(ctx, args[2], "if")
+	}
+}
+
+/**
+ * Run the body of the first condition that passes, with :else passing
+ * unconditionally. Conditions after the first one to pass aren't checked.
+ *   (:cond CONDITION (:link ...) CONDITION (:link ...) :else (:link ...))
+ */
+func dCond(ctx *Context, args AnySlice) {
+	if len(args)%2 != 0 {
+		log.Error().Interface("args", args).
+			Msgf("%s directive must be given pairs of conditions and bodies", edn.Keyword("cond"))
+		return
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		if args[i] == edn.Keyword("else") || dCondition(ctx, args[i]) {
+			dispatchBranch(ctx, args[i+1], "cond")
+			return
+		}
+	}
+}
+
+// dispatch the body of a branch in a conditional directive, which is either
+// a single directive or a list of them.
+func dispatchBranch(ctx *Context, branch Any, name string) {
+	body, ok := branch.(AnySlice)
+	if !ok {
+		log.Error().Interface("body", branch).
+			Msgf("%s body must be a directive or a list of directives, not %T", edn.Keyword(name), branch)
+		return
+	}
+	if len(body) > 0 {
+		if _, ok := body[0].(edn.Keyword); ok {
+			body = AnySlice{body}
+		}
+	}
+	dispatchDirectives(ctx, body)
+}
+
 // makes conditional subcommands silent (they don't output anything) by default.
 var dConditionDefaultCmdOpts = map[string]bool{"interactive": false, "quiet": true}
 
@@ -36,6 +96,9 @@ func dConditionPrepareCmd(opts map[Any]Any) map[Any]Any {
  *
  *  An assertion, such as we're installing this bot:
  *   (:bot "git")
+ *
+ *  A native predicate (see dConditionPredicates), such as:
+ *   (:exists "~/.config/foo") or (:os "linux" "darwin")
  */
 func dCondition(ctx *Context, arg Any) bool {
 	res := false
@@ -51,6 +114,10 @@ func dCondition(ctx *Context, arg Any) bool {
 		if modifier, ok := cmdSlice[0].(edn.Keyword); ok {
 			switch modifier {
 			case edn.Keyword("not"):
+				if len(cmdSlice) == 2 {
+					// a single condition, which may itself be a predicate.
+					return !dCondition(ctx, cmdSlice[1])
+				}
 				return !dCondition(ctx, cmdSlice[1:])
 			case edn.Keyword("bots"):
 				fallthrough
@@ -71,6 +138,9 @@ func dCondition(ctx *Context, arg Any) bool {
 				}
 				return false
 			default:
+				if predicate, ok := dConditionPredicates[modifier]; ok {
+					return predicate(ctx, cmdSlice[1:])
+				}
 				log.Warn().Interface("condition", modifier).
 					Msg("Unknown condition in when directive")
 				return res
@@ -0,0 +1,308 @@
+package pkg
+
+import (
+	"os"
+	"os/exec"
+	fp "path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"olympos.io/encoding/edn"
+)
+
+/**
+ * Native predicates for conditions, checked without spawning a shell. Each
+ * predicate is given the arguments following its keyword, for example
+ * (:exists "~/.foo" "~/.bar") calls the :exists predicate with both paths.
+ */
+var dConditionPredicates = map[edn.Keyword]func(ctx *Context, args AnySlice) bool{
+	edn.Keyword("exists"):     dConditionPathPredicate("exists", followingSymlinks(pathExists)),
+	edn.Keyword("file"):       dConditionPathPredicate("file", followingSymlinks(fileExists)),
+	edn.Keyword("dir"):        dConditionPathPredicate("dir", followingSymlinks(dirExists)),
+	edn.Keyword("link"):       dConditionPathPredicate("link", isSymlink),
+	edn.Keyword("executable"): dConditionExecutable,
+	edn.Keyword("env"):        dConditionEnv,
+	edn.Keyword("env="):       dConditionEnvEquals,
+	edn.Keyword("os"):         dConditionMatchesAny("os", func() string { return runtime.GOOS }, nil),
+	edn.Keyword("arch"):       dConditionMatchesAny("arch", func() string { return runtime.GOARCH }, dConditionArchAliases),
+	edn.Keyword("hostname"):   dConditionHostname,
+	edn.Keyword("version"):    dConditionVersion,
+	edn.Keyword("profile"):    dConditionProfile,
+}
+
+// read args as strings, expanding any environment variables in them.
+func dConditionStrings(ctx *Context, args AnySlice, name string) ([]string, bool) {
+	if len(args) == 0 {
+		log.Warn().Msgf("%s predicate must be given at least one argument", edn.Keyword(name))
+		return nil, false
+	}
+
+	res := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(string)
+		if !ok {
+			log.Error().Interface("arg", arg).
+				Msgf("%s predicate can only accept strings, not %T", edn.Keyword(name), arg)
+			return nil, false
+		}
+		if res[i], ok = ctx.eval(str); !ok {
+			return nil, false
+		}
+	}
+	return res, true
+}
+
+// a predicate that passes when every one of its paths satisfies check.
+func dConditionPathPredicate(name string, check func(path string) bool) func(ctx *Context, args AnySlice) bool {
+	return func(ctx *Context, args AnySlice) bool {
+		paths, ok := dConditionStrings(ctx, args, name)
+		if !ok {
+			return false
+		}
+		for _, path := range paths {
+			path = ExpandTilde(ctx.Home, JoinPath(ctx.Cwd, fp.FromSlash(path)))
+			if !check(path) {
+				log.Trace().Str("path", path).
+					Msgf("Condition %s failed", edn.Keyword(name))
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// adapt one of the pathExists family of checks to follow symlinks.
+func followingSymlinks(check func(path string, followSymlinks bool) (bool, error)) func(string) bool {
+	return func(path string) bool {
+		ok, _ := check(path, true)
+		return ok
+	}
+}
+
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// passes when every argument can be found on the PATH of the context.
+func dConditionExecutable(ctx *Context, args AnySlice) bool {
+	progs, ok := dConditionStrings(ctx, args, "executable")
+	if !ok {
+		return false
+	}
+	for _, prog := range progs {
+		if _, ok := ctx.lookPath(prog); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * Search for the executable prog in the directories of the contexts PATH, like
+ * exec.LookPath, except respecting any PATH set through :def. On windows files
+ * with one of the extensions in PATHEXT are also checked.
+ */
+func (ctx *Context) lookPath(prog string) (string, bool) {
+	exts := []string{""}
+	if isWindows() {
+		pathExt, _ := ctx.lookupEnv("PATHEXT")
+		if pathExt == "" {
+			pathExt = ".com;.exe;.bat;.cmd"
+		}
+		exts = append(exts, strings.Split(strings.ToLower(pathExt), ";")...)
+	}
+	isExecutable := func(path string) bool {
+		for _, ext := range exts {
+			if info, err := os.Stat(path + ext); err == nil && info.Mode().IsRegular() &&
+				(isWindows() || info.Mode().Perm()&0111 != 0) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if strings.ContainsAny(prog, `/\`) {
+		prog = ExpandTilde(ctx.Home, JoinPath(ctx.Cwd, fp.FromSlash(prog)))
+		return prog, isExecutable(prog)
+	}
+	path, _ := ctx.lookupEnv("PATH")
+	for _, dir := range fp.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		if candidate := fp.Join(dir, prog); isExecutable(candidate) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// passes when every argument is an environment variable with a non-empty value.
+func dConditionEnv(ctx *Context, args AnySlice) bool {
+	names, ok := dConditionStrings(ctx, args, "env")
+	if !ok {
+		return false
+	}
+	for _, name := range names {
+		if val, _ := ctx.lookupEnv(name); val == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// passes when an environment variable is set to exactly the given value.
+func dConditionEnvEquals(ctx *Context, args AnySlice) bool {
+	if len(args) != 2 {
+		log.Error().Interface("args", args).
+			Msgf("%s predicate must be given a variable name and a value", edn.Keyword("env="))
+		return false
+	}
+	strs, ok := dConditionStrings(ctx, args, "env=")
+	if !ok {
+		return false
+	}
+	val, ok := ctx.lookupEnv(strs[0])
+	return ok && val == strs[1]
+}
+
+// alternative names for architectures, as reported by uname -m.
+var dConditionArchAliases = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"i386":    "386",
+	"i686":    "386",
+}
+
+// a predicate that passes when value matches any one of its arguments, after
+// resolving aliases.
+func dConditionMatchesAny(name string, value func() string, aliases map[string]string) func(ctx *Context, args AnySlice) bool {
+	return func(ctx *Context, args AnySlice) bool {
+		candidates, ok := dConditionStrings(ctx, args, name)
+		if !ok {
+			return false
+		}
+		actual := value()
+		for _, candidate := range candidates {
+			if alias, ok := aliases[candidate]; ok {
+				candidate = alias
+			}
+			if candidate == actual {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// the hostname of the current machine, overridable for tests.
+var dConditionHostnameFunc = os.Hostname
+
+// passes when the hostname matches any of the glob patterns given.
+func dConditionHostname(ctx *Context, args AnySlice) bool {
+	patterns, ok := dConditionStrings(ctx, args, "hostname")
+	if !ok {
+		return false
+	}
+	hostname, err := dConditionHostnameFunc()
+	if err != nil {
+		log.Error().Str("error", err.Error()).
+			Msg("Failed to determine hostname")
+		return false
+	}
+	for _, pattern := range patterns {
+		if ok, err := fp.Match(pattern, hostname); err != nil {
+			log.Error().Str("pattern", pattern).
+				Str("error", err.Error()).
+				Msgf("Invalid pattern in %s predicate", edn.Keyword("hostname"))
+		} else if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// versions of programs we've already checked in this run, keyed by the
+// command we ran to find them and the PATH we ran it with.
+var dConditionVersionCache = struct {
+	sync.Mutex
+	versions map[string]version
+}{versions: make(map[string]version)}
+
+/**
+ * passes when the version of a program satisfies a constraint, such as
+ * (:version "git" ">= 2.30"). The program is run with --version unless
+ * it's given as a list with the arguments to use instead, such as
+ * (:version ("java" "-version") ">= 11"). The first version number in its
+ * output is used.
+ */
+func dConditionVersion(ctx *Context, args AnySlice) bool {
+	if len(args) != 2 {
+		log.Error().Interface("args", args).
+			Msgf("%s predicate must be given a program and a version constraint", edn.Keyword("version"))
+		return false
+	}
+
+	var cmd []string
+	if prog, ok := args[0].(AnySlice); ok {
+		if cmd, ok = dConditionStrings(ctx, prog, "version"); !ok {
+			return false
+		}
+	} else if prog, ok := dConditionStrings(ctx, args[:1], "version"); ok {
+		cmd = []string{prog[0], "--version"}
+	} else {
+		return false
+	}
+	constraint, ok := dConditionStrings(ctx, args[1:], "version")
+	if !ok {
+		return false
+	}
+	constraints, err := parseVersionConstraints(constraint[0])
+	if err != nil {
+		log.Error().Str("error", err.Error()).
+			Msgf("Invalid constraint in %s predicate", edn.Keyword("version"))
+		return false
+	}
+
+	v, ok := ctx.programVersion(cmd)
+	if !ok {
+		return false
+	}
+	log.Trace().Strs("cmd", cmd).
+		Str("version", v.String()).
+		Str("constraint", constraint[0]).
+		Msg("Checking program version")
+	return versionMatches(v, constraints)
+}
+
+// run cmd and find the version number in its output, caching the result.
+func (ctx *Context) programVersion(cmd []string) (version, bool) {
+	path, _ := ctx.lookupEnv("PATH")
+	key := path + "\x00" + strings.Join(cmd, "\x00")
+	dConditionVersionCache.Lock()
+	defer dConditionVersionCache.Unlock()
+	if v, ok := dConditionVersionCache.versions[key]; ok {
+		return v, v != nil
+	}
+
+	var v version
+	if prog, ok := ctx.lookPath(cmd[0]); !ok {
+		log.Debug().Str("prog", cmd[0]).
+			Msg("Unable to find program to check version of")
+	} else {
+		proc := exec.Command(prog, cmd[1:]...)
+		proc.Dir, proc.Env = ctx.Cwd, ctx.environ()
+		// some programs print their version to stderr, or exit with an error
+		// after printing it, so we only care if there's a version in the output.
+		output, _ := proc.CombinedOutput()
+		if v, ok = findVersion(string(output)); !ok {
+			log.Warn().Strs("cmd", cmd).
+				Msg("Unable to find version in output of command")
+		}
+	}
+	dConditionVersionCache.versions[key] = v
+	return v, v != nil
+}
@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"runtime"
+	"testing"
+
+	"olympos.io/encoding/edn"
+)
+
+func testCondition(t *testing.T, ctx *Context, condition string) bool {
+	var arg Any
+	if err := edn.Unmarshal([]byte(condition), &arg); err != nil {
+		t.Fatalf("failed to parse condition %s: %s", condition, err)
+	}
+	return dCondition(ctx, arg)
+}
+
+func TestDCondition_PathPredicates(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "dotty-when")
+	defer os.RemoveAll(tmp)
+	ioutil.WriteFile(fp.Join(tmp, "foo"), []byte("foo"), 0755)
+	os.Mkdir(fp.Join(tmp, "bar"), 0755)
+	os.Symlink("foo", fp.Join(tmp, "baz"))
+
+	ctx := CreateContext()
+	ctx.Cwd, ctx.Home = tmp, tmp
+	testCases := map[string]bool{
+		`(:exists "foo" "~/bar")`:           true,
+		`(:exists "foo" "missing")`:         false,
+		`(:file "foo")`:                     true,
+		`(:file "bar")`:                     false,
+		`(:dir "bar")`:                      true,
+		`(:link "baz")`:                     true,
+		`(:link "foo")`:                     false,
+		`(:not (:exists "missing"))`:        true,
+		`(:and (:file "baz") (:dir "bar"))`: true,
+	}
+	for condition, expected := range testCases {
+		if res := testCondition(t, ctx, condition); res != expected {
+			t.Errorf("expected %s to be %t", condition, expected)
+		}
+	}
+}
+
+func TestDCondition_EnvironmentPredicates(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "dotty-when")
+	defer os.RemoveAll(tmp)
+	ioutil.WriteFile(fp.Join(tmp, "prog"), []byte("#!/bin/sh\n"), 0755)
+	ioutil.WriteFile(fp.Join(tmp, "data"), []byte(""), 0644)
+
+	ctx := CreateContext()
+	ctx.Cwd, ctx.Home = tmp, tmp
+	ctx.envOpts["PATH"] = tmp
+	ctx.envOpts["DOTTY_FOO"] = "foo"
+	ctx.envOpts["DOTTY_EMPTY"] = ""
+	dConditionHostnameFunc = func() (string, error) { return "work-laptop", nil }
+	defer func() { dConditionHostnameFunc = os.Hostname }()
+
+	testCases := map[string]bool{
+		`(:executable "prog")`:                     true,
+		`(:executable "data")`:                     false,
+		`(:executable "missing")`:                  false,
+		`(:env "DOTTY_FOO")`:                       true,
+		`(:env "DOTTY_EMPTY")`:                     false,
+		`(:env= "DOTTY_FOO" "foo")`:                true,
+		`(:env= "DOTTY_FOO" "bar")`:                false,
+		`(:os "` + runtime.GOOS + `")`:             true,
+		`(:os "plan10")`:                           false,
+		`(:arch "sparc" "` + runtime.GOARCH + `")`: true,
+		`(:hostname "home-*" "work-*")`:            true,
+		`(:hostname "home-*")`:                     false,
+	}
+	for condition, expected := range testCases {
+		if res := testCondition(t, ctx, condition); res != expected {
+			t.Errorf("expected %s to be %t", condition, expected)
+		}
+	}
+}
+
+func TestDCondition_VersionPredicate(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "dotty-when")
+	defer os.RemoveAll(tmp)
+	ioutil.WriteFile(fp.Join(tmp, "tool"), []byte("#!/bin/sh\necho \"tool $1 1.4.2\" >&2\n"), 0755)
+
+	ctx := CreateContext()
+	ctx.Cwd, ctx.Home = tmp, tmp
+	ctx.envOpts["PATH"] = tmp
+	testCases := map[string]bool{
+		`(:version "tool" ">= 1.4")`:          true,
+		`(:version "tool" ">= 1.4, < 1.4.2")`: false,
+		`(:version ("tool" "-V") "~1.4")`:     true,
+		`(:version "missing" ">= 0")`:         false,
+		`(:not (:version "tool" "^2"))`:       true,
+	}
+	for condition, expected := range testCases {
+		if res := testCondition(t, ctx, condition); res != expected {
+			t.Errorf("expected %s to be %t", condition, expected)
+		}
+	}
+}
+
+func TestDirectiveMapCondition_IfIsAliasForWhen(t *testing.T) {
+	ctx := CreateContext()
+	ctx.envOpts["DOTTY_FOO"] = "foo"
+	testCases := map[string]bool{
+		`{:if (:env "DOTTY_FOO")}`:                              true,
+		`{:if (:env "DOTTY_MISSING")}`:                          false,
+		`{:when (:env "DOTTY_FOO") :if (:env "DOTTY_MISSING")}`: false,
+	}
+	for opts, expected := range testCases {
+		var arg map[Any]Any
+		if err := edn.Unmarshal([]byte(opts), &arg); err != nil {
+			t.Fatalf("failed to parse options %s: %s", opts, err)
+		}
+		if res := directiveMapCondition(ctx, arg); res != expected {
+			t.Errorf("expected %s to be %t", opts, expected)
+		}
+	}
+}
+
+func TestDIf_RunsOneBranch(t *testing.T) {
+	testCases := map[string]string{
+		`((:if (:env "DOTTY_FOO") (:def "RES" "then") (:def "RES" "else")))`:             "then",
+		`((:if (:env "DOTTY_MISSING") (:def "RES" "then") (:def "RES" "else")))`:         "else",
+		`((:if (:env "DOTTY_MISSING") (:def "RES" "then") :else ((:def "RES" "else"))))`: "else",
+		`((:if (:env "DOTTY_MISSING") (:def "RES" "then")))`:                             "",
+	}
+	for script, expected := range testCases {
+		ctx := CreateContext()
+		ctx.envOpts["DOTTY_FOO"] = "foo"
+		dispatchDirectives(ctx, pathsFromEdn(script))
+		if res := ctx.envOpts["RES"]; res != expected {
+			t.Errorf("expected %s to set RES to %q, got %q", script, expected, res)
+		}
+	}
+}
+
+func TestDCond_RunsFirstPassingBranch(t *testing.T) {
+	testCases := map[string]string{
+		`((:cond (:env "DOTTY_FOO") (:def "RES" "foo") (:env "DOTTY_BAR") (:def "RES" "bar")))`:     "foo",
+		`((:cond (:env "DOTTY_MISSING") (:def "RES" "foo") (:env "DOTTY_BAR") (:def "RES" "bar")))`: "bar",
+		`((:cond (:env "DOTTY_MISSING") (:def "RES" "foo") :else ((:def "RES" "else"))))`:           "else",
+		`((:cond (:env "DOTTY_MISSING") (:def "RES" "foo")))`:                                       "",
+	}
+	for script, expected := range testCases {
+		ctx := CreateContext()
+		ctx.envOpts["DOTTY_FOO"] = "foo"
+		ctx.envOpts["DOTTY_BAR"] = "bar"
+		dispatchDirectives(ctx, pathsFromEdn(script))
+		if res := ctx.envOpts["RES"]; res != expected {
+			t.Errorf("expected %s to set RES to %q, got %q", script, expected, res)
+		}
+	}
+}
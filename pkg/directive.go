@@ -2,6 +2,9 @@ package pkg
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"strconv"
 
 	"github.com/rs/zerolog/log"
 	"olympos.io/encoding/edn"
@@ -20,6 +23,13 @@ type directive interface {
 	Log() string
 }
 
+// A directive that can show how it would change existing files.
+type Differ interface {
+	// write a unified diff from the current contents of each file this
+	// directive would replace, to what it'd be replaced with, into w.
+	Diff(w io.Writer)
+}
+
 type directiveConstructor = func(ctx *Context, args AnySlice)
 
 var Directives map[edn.Keyword]directiveConstructor
@@ -33,13 +43,29 @@ func init() {
 		edn.Keyword("shell"):    dShell,
 		edn.Keyword("clean"):    dClean,
 		edn.Keyword("when"):     dWhen,
+		edn.Keyword("if"):       dIf,
+		edn.Keyword("cond"):     dCond,
 		edn.Keyword("debug"):    dDebug,
 		edn.Keyword("info"):     dInfo,
 		edn.Keyword("warn"):     dWarn,
 		edn.Keyword("def"):      dDef,
+		edn.Keyword("let"):      dLet,
+		edn.Keyword("env-file"): dEnvFile,
 		edn.Keyword("package"):  dPackage,
 		edn.Keyword("packages"): dPackage,
 		edn.Keyword("ignore"):   dIgnore,
+		edn.Keyword("chmod"):    dChmod,
+		edn.Keyword("sync"):     dSync,
+		edn.Keyword("merge"):    dMerge,
+		edn.Keyword("assemble"): dAssemble,
+		edn.Keyword("file"):     dFile,
+		edn.Keyword("patch"):    dPatch,
+		edn.Keyword("git"):      dGit,
+		edn.Keyword("extract"):  dExtract,
+		edn.Keyword("download"): dDownload,
+
+		edn.Keyword("line-in-file"):  dLineInFile,
+		edn.Keyword("block-in-file"): dBlockInFile,
 	}
 }
 
@@ -98,7 +124,8 @@ func dispatchDirectives(ctx *Context, directives AnySlice) {
 // These conditions are the same as a general purpose :when directive.
 // opts can also include a :if-bots directive which is just a shortcut
 // for `:when (:bots ARGS)`, because that's most likely what this is
-// going to be used for.
+// going to be used for. :if is an alias for :when, when both are given
+// they must both pass.
 func directiveMapCondition(ctx *Context, opts map[Any]Any) bool {
 	res := true
 	if bots, ok := opts[edn.Keyword("if-bots")]; ok {
@@ -111,8 +138,10 @@ func directiveMapCondition(ctx *Context, opts map[Any]Any) bool {
 		}
 	}
 
-	if when, ok := opts[edn.Keyword("when")]; res && ok {
-		res = dCondition(ctx, when)
+	for _, key := range []string{"when", "if"} {
+		if cond, ok := opts[edn.Keyword(key)]; res && ok {
+			res = dCondition(ctx, cond)
+		}
 	}
 
 	return res
@@ -161,3 +190,269 @@ func readMapOptionString(ctxOpts map[string]Any, opts map[Any]Any, field *string
 	}
 	return true
 }
+
+// same as readMapOptionBool but for integers.
+func readMapOptionInt(ctxOpts map[string]Any, opts map[Any]Any, field *int, name string, def int) bool {
+	*field = def // assign default
+
+	opt, ok := ctxOpts[name]
+	// override value from context with value from map (when provided).
+	if optVal, optOk := opts[edn.Keyword(name)]; optOk {
+		opt = optVal
+		ok = true
+	}
+	if ok {
+		if optInt, ok := opt.(int64); ok {
+			*field = int(optInt) // update value
+		} else {
+			log.Warn().Msgf("%s should be an integer value, not %T", name, opt)
+			return false
+		}
+	}
+	return true
+}
+
+// same as readMapOptionString but accepts either a single string or a list
+// of strings, always assigning a slice to field.
+func readMapOptionStrings(ctxOpts map[string]Any, opts map[Any]Any, field *[]string, name string, def []string) bool {
+	*field = def // assign default
+
+	opt, ok := ctxOpts[name]
+	// override value from context with value from map (when provided).
+	if optVal, optOk := opts[edn.Keyword(name)]; optOk {
+		opt = optVal
+		ok = true
+	}
+	if !ok {
+		return true
+	}
+
+	if optString, ok := opt.(string); ok {
+		*field = []string{optString}
+		return true
+	} else if optSlice, ok := opt.(AnySlice); ok {
+		strs := make([]string, 0, len(optSlice))
+		for _, val := range optSlice {
+			if valString, ok := val.(string); ok {
+				strs = append(strs, valString)
+			} else {
+				log.Warn().Msgf("%s should be a list of strings, not a list containing %T", name, val)
+				return false
+			}
+		}
+		*field = strs
+		return true
+	}
+
+	log.Warn().Msgf("%s should be a string or a list of strings, not %T", name, opt)
+	return false
+}
+
+// parse a file mode in octal notation from val. The mode can be
+// given as either a number or a string, eg. 700 or "0700".
+func parseFileMode(val Any) (os.FileMode, bool) {
+	mode, err := strconv.ParseUint(fmt.Sprintf("%v", val), 8, 32)
+	if err != nil || mode&^uint64(os.ModePerm|0o7000) != 0 {
+		return 0, false
+	}
+	// translate the unix setuid, setgid and sticky bits to go's own.
+	res := os.FileMode(mode) & os.ModePerm
+	if mode&0o4000 != 0 {
+		res |= os.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		res |= os.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		res |= os.ModeSticky
+	}
+	return res, true
+}
+
+// same as readMapOptionBool but for file modes. see parseFileMode.
+func readMapOptionMode(ctxOpts map[string]Any, opts map[Any]Any, field *os.FileMode, name string, def os.FileMode) bool {
+	*field = def // assign default
+
+	opt, ok := ctxOpts[name]
+	// override value from context with value from map (when provided).
+	if optVal, optOk := opts[edn.Keyword(name)]; optOk {
+		opt = optVal
+		ok = true
+	}
+	if ok {
+		if mode, ok := parseFileMode(opt); ok {
+			*field = mode // update value
+		} else {
+			log.Warn().Str(name, fmt.Sprintf("%v", opt)).
+				Msgf("%s must be a valid file permission in octal notation, not %T", name, opt)
+			return false
+		}
+	}
+	return true
+}
+
+// read the :state option, asserting whether it's absent.
+func readMapOptionState(ctxOpts map[string]Any, opts map[Any]Any, absent *bool) bool {
+	var state string
+	if !readMapOptionString(ctxOpts, opts, &state, "state", "present") {
+		return false
+	}
+	switch state {
+	case "present":
+		*absent = false
+	case "absent":
+		*absent = true
+	default:
+		log.Warn().Str("state", state).
+			Msg("state must be either present or absent")
+		return false
+	}
+	return true
+}
+
+/**
+ * Constructor for directives that take a src and dest, as either a map of
+ * options with a :src and :dest or a src path followed by a dest path. build
+ * is called for every combination of src and dest found, alongside the map
+ * of options (if any) they came from.
+ */
+func dSrcDestPairs(ctx *Context, args AnySlice, name string, build func(opts map[Any]Any, src, dest string)) {
+	dSrcDestArgs(ctx, args, name, func(opts map[Any]Any, srcArg, destArg Any) {
+		srcs, ok := dLinkGeneratePaths(ctx.Cwd, ctx.evalPaths, srcArg, "src")
+		if !ok {
+			return
+		}
+		dests, ok := dLinkGeneratePaths(ctx.Cwd, ctx.evalPaths, destArg, "dest")
+		if !ok {
+			return
+		}
+		for _, src := range srcs {
+			for _, dest := range dests {
+				build(opts, ExpandTilde(ctx.Home, src), ExpandTilde(ctx.Home, dest))
+			}
+		}
+	})
+}
+
+// like dSrcDestPairs, but build is given the src and dest as they appear in
+// args, for directives whose src isn't just a path.
+func dSrcDestArgs(ctx *Context, args AnySlice, name string, build func(opts map[Any]Any, src, dest Any)) {
+	for i := 0; i < len(args); i++ {
+		if opts, ok := args[i].(map[Any]Any); ok {
+			if !directiveMapCondition(ctx, opts) {
+				continue
+			}
+			src, srcOk := opts[edn.Keyword("src")]
+			dest, destOk := opts[edn.Keyword("dest")]
+			if !srcOk || !destOk {
+				log.Error().Interface("spec", opts).
+					Msgf("%s directive must specify a %s and %s",
+						edn.Keyword(name), edn.Keyword("src"), edn.Keyword("dest"))
+				continue
+			}
+			build(opts, src, dest)
+		} else {
+			if i == len(args)-1 {
+				log.Error().Interface("src", args[i]).
+					Msgf("%s src with no destination encountered", edn.Keyword(name))
+				continue
+			}
+			build(nil, args[i], args[i+1])
+			i++
+		}
+	}
+}
+
+/**
+ * Constructor for directives that fetch from a remote (such as a URL) into a
+ * dest, as either a map of options with a remoteKey and :dest or the remote
+ * followed by a dest path. Unlike dSrcDestPairs the remote is only expanded,
+ * not resolved as a path.
+ */
+func dRemoteDestPairs(ctx *Context, args AnySlice, name, remoteKey string, build func(opts map[Any]Any, remote, dest string)) {
+	for i := 0; i < len(args); i++ {
+		var remote, dest Any
+		opts, ok := args[i].(map[Any]Any)
+		if ok {
+			if !directiveMapCondition(ctx, opts) {
+				continue
+			}
+			var destOk bool
+			remote, ok = opts[edn.Keyword(remoteKey)].(string)
+			dest, destOk = opts[edn.Keyword("dest")]
+			if !ok || !destOk {
+				log.Error().Interface("spec", opts).
+					Msgf("%s directive must specify a %s string and %s",
+						edn.Keyword(name), edn.Keyword(remoteKey), edn.Keyword("dest"))
+				continue
+			}
+		} else {
+			if i == len(args)-1 {
+				log.Error().Interface(remoteKey, args[i]).
+					Msgf("%s %s with no destination encountered", edn.Keyword(name), remoteKey)
+				continue
+			}
+			remote, dest = args[i], args[i+1]
+			i++
+		}
+
+		remoteStr, ok := remote.(string)
+		if !ok {
+			log.Error().Interface(remoteKey, remote).
+				Msgf("%s %s must be a string", edn.Keyword(name), remoteKey)
+			continue
+		}
+		if remoteStr, ok = ctx.eval(remoteStr); !ok {
+			continue
+		}
+		dests, ok := dLinkGeneratePaths(ctx.Cwd, ctx.evalPaths, dest, "dest")
+		if !ok {
+			continue
+		}
+		for _, dest := range dests {
+			build(opts, remoteStr, ExpandTilde(ctx.Home, dest))
+		}
+	}
+}
+
+/**
+ * Constructor for directives that edit a file. Each arg is either a map of
+ * options with a pathKey or a path followed by the value for the option
+ * valueKey. build is called for every path found, alongside the options.
+ */
+func dPathValuePairs(ctx *Context, args AnySlice, pathKey, valueKey string, build func(path string, opts map[Any]Any) (directive, bool)) {
+	construct := func(pathArg Any, opts map[Any]Any) {
+		paths, ok := dLinkGeneratePaths(ctx.Cwd, ctx.evalPaths, pathArg, pathKey)
+		if !ok {
+			return
+		}
+		for _, path := range paths {
+			if dir, ok := build(ExpandTilde(ctx.Home, path), opts); ok {
+				ctx.DirChan <- dir
+			}
+		}
+	}
+
+	for i := 0; i < len(args); i++ {
+		if opts, ok := args[i].(map[Any]Any); ok {
+			if !directiveMapCondition(ctx, opts) {
+				continue
+			}
+			path, ok := opts[edn.Keyword(pathKey)]
+			if !ok {
+				log.Error().Interface("spec", opts).
+					Msgf("Directive must specify a %s", edn.Keyword(pathKey))
+				continue
+			}
+			construct(path, opts)
+		} else {
+			if i == len(args)-1 {
+				log.Error().Interface(pathKey, args[i]).
+					Msgf("Path with no %s encountered", edn.Keyword(valueKey))
+				continue
+			}
+			construct(args[i], map[Any]Any{edn.Keyword(valueKey): args[i+1]})
+			i++
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"os"
+	"testing"
+
+	"olympos.io/encoding/edn"
+)
+
+func TestParseFileMode_ReadsOctalNumbersAndStrings(t *testing.T) {
+	testCases := []struct {
+		arg  Any
+		mode os.FileMode
+	}{
+		{int64(700), 0700},
+		{"0600", 0600},
+		{"644", 0644},
+		{int64(4755), 0755 | os.ModeSetuid},
+		{"1777", 0777 | os.ModeSticky},
+	}
+
+	for _, test := range testCases {
+		mode, ok := parseFileMode(test.arg)
+		if !ok {
+			t.Errorf("failed to parse mode: %v", test.arg)
+		} else if mode != test.mode {
+			t.Errorf("mode mismatch: expected != actual, %v != %v", test.mode, mode)
+		}
+
+		if res, _ := parseFileMode(formatMode(mode)); res != mode {
+			t.Errorf("formatted mode doesn't round trip: %v != %v", mode, res)
+		}
+	}
+}
+
+func TestParseFileMode_RejectsInvalidModes(t *testing.T) {
+	for _, arg := range []Any{"rwx", int64(800), "17777", true} {
+		if _, ok := parseFileMode(arg); ok {
+			t.Errorf("parsed invalid mode: %v", arg)
+		}
+	}
+}
+
+func TestInvalidModeRejectsDirective(t *testing.T) {
+	testCases := []struct {
+		name  string
+		modes []string
+		init  func(map[Any]Any) bool
+	}{
+		{"link", []string{"mode", "dir-mode"}, func(opts map[Any]Any) bool {
+			_, ok := (&linkDirective{src: []string{"/dots/foo"}, dest: []string{"/home/.foo"}}).init(CreateContext(), opts)
+			return ok
+		}},
+		{"file", []string{"mode"}, func(opts map[Any]Any) bool {
+			opts[edn.Keyword("content")] = "foo"
+			_, ok := (&fileDirective{dest: "/home/.foo"}).init(CreateContext(), opts)
+			return ok
+		}},
+		{"download", []string{"mode"}, func(opts map[Any]Any) bool {
+			_, ok := (&downloadDirective{url: "https://example.com/foo", dest: "/home/foo"}).init(CreateContext(), opts)
+			return ok
+		}},
+	}
+
+	for _, test := range testCases {
+		for _, mode := range test.modes {
+			if test.init(map[Any]Any{edn.Keyword(mode): "abc"}) {
+				t.Errorf("expected %s with an invalid %s to be rejected", test.name, mode)
+			}
+			if !test.init(map[Any]Any{edn.Keyword(mode): "0644"}) {
+				t.Errorf("expected %s with a valid %s to be accepted", test.name, mode)
+			}
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package pkg
 
 import (
+	"bytes"
 	"fmt"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"olympos.io/encoding/edn"
 )
 
@@ -13,6 +18,19 @@ func pathsFromEdn(data string) AnySlice {
 	return paths
 }
 
-func identStr(a string) (string, bool) {
-	return a, true
+// capture any warnings or errors logged for the rest of the test.
+func testCaptureWarnings(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(&buf)
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	t.Cleanup(func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	})
+	return &buf
+}
+
+func identStr(a string) ([]string, bool) {
+	return []string{a}, true
 }
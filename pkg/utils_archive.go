@@ -0,0 +1,250 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	fp "path/filepath"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+// the archive formats we can extract.
+const (
+	archiveTar   = "tar"
+	archiveTarGz = "tar.gz"
+	archiveTarXz = "tar.xz"
+	archiveZip   = "zip"
+)
+
+// guess the format of an archive from its extension.
+func archiveFormatFromPath(path string) (string, bool) {
+	name := strings.ToLower(fp.Base(path))
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return archiveTarGz, true
+	case strings.HasSuffix(name, ".tar.xz"), strings.HasSuffix(name, ".txz"):
+		return archiveTarXz, true
+	case strings.HasSuffix(name, ".tar"):
+		return archiveTar, true
+	case strings.HasSuffix(name, ".zip"):
+		return archiveZip, true
+	}
+	return "", false
+}
+
+/**
+ * Work out where the archive entry name should be extracted to in dest,
+ * after removing strip leading components from it. Returns false when
+ * nothing is left of the name. Entries that'd end up outside of dest are
+ * rejected, so a malicious archive can't write anywhere else. This includes
+ * entries that go through symlinks extracted from earlier entries (such as
+ * a -> . followed by a/b -> .. and then a/b/evil), so dest must be a path
+ * with its own symlinks already resolved.
+ */
+func archiveEntryPath(dest, name string, strip int) (string, bool, error) {
+	parts := strings.Split(strings.Trim(strings.ReplaceAll(name, "\\", "/"), "/"), "/")
+	if len(parts) <= strip {
+		return "", false, nil
+	}
+
+	path := fp.Join(dest, fp.FromSlash(strings.Join(parts[strip:], "/")))
+	if path == dest || !fileIsRelative(path, dest) {
+		return "", false, fmt.Errorf("archive entry %s is outside of the destination", name)
+	}
+
+	parent, err := archiveRealPath(fp.Dir(path))
+	if err != nil {
+		return "", false, err
+	} else if !fileIsRelative(parent, dest) {
+		return "", false, fmt.Errorf("archive entry %s is outside of the destination", name)
+	}
+	return fp.Join(parent, fp.Base(path)), true, nil
+}
+
+// resolve any symlinks in path, as far as it exists.
+func archiveRealPath(path string) (string, error) {
+	real, err := fp.EvalSymlinks(path)
+	if err == nil || !os.IsNotExist(err) {
+		return real, err
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return "", fmt.Errorf("archive symlink %s is broken", path)
+	}
+
+	parent := fp.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	if parent, err = archiveRealPath(parent); err != nil {
+		return "", err
+	}
+	return fp.Join(parent, fp.Base(path)), nil
+}
+
+/**
+ * Extract the archive at src, in format, into the directory dest removing
+ * strip leading components from the path of each entry.
+ */
+func extractArchive(src, format, dest string, strip int) error {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	dest, err := fp.EvalSymlinks(dest)
+	if err != nil {
+		return err
+	}
+
+	if format == archiveZip {
+		return extractZip(src, dest, strip)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch format {
+	case archiveTarGz:
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case archiveTarXz:
+		if r, err = xz.NewReader(f); err != nil {
+			return err
+		}
+	case archiveTar:
+	default:
+		return fmt.Errorf("unknown archive format %s", format)
+	}
+	return extractTar(r, dest, strip)
+}
+
+func extractTar(r io.Reader, dest string, strip int) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		path, ok, err := archiveEntryPath(dest, header.Name, strip)
+		if err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, mode|0700)
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeArchiveFile(path, tr, mode)
+		case tar.TypeSymlink:
+			err = writeArchiveSymlink(dest, path, header.Linkname)
+		case tar.TypeLink:
+			var target string
+			if target, ok, err = archiveEntryPath(dest, header.Linkname, strip); err == nil && ok {
+				err = os.Link(target, path)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(src, dest string, strip int) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, file := range zr.File {
+		path, ok, err := archiveEntryPath(dest, file.Name, strip)
+		if err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+
+		mode := file.Mode()
+		if mode.IsDir() {
+			if err := os.MkdirAll(path, mode.Perm()|0700); err != nil {
+				return err
+			}
+			continue
+		}
+
+		r, err := file.Open()
+		if err != nil {
+			return err
+		}
+		if mode&os.ModeSymlink != 0 {
+			var target strings.Builder
+			if _, err = io.Copy(&target, r); err == nil {
+				err = writeArchiveSymlink(dest, path, target.String())
+			}
+		} else {
+			err = writeArchiveFile(path, r, mode.Perm())
+		}
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeArchiveFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(fp.Dir(path), 0755); err != nil {
+		return err
+	}
+	// replace, rather than write through, a symlink from an earlier entry.
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// create a symlink at path to target, as long as target is within dest.
+func writeArchiveSymlink(dest, path, target string) error {
+	if err := os.MkdirAll(fp.Dir(path), 0755); err != nil {
+		return err
+	}
+	resolved := target
+	if !fp.IsAbs(resolved) {
+		// not joined, so .. follows any symlinks before it like the OS would.
+		resolved = fp.Dir(path) + string(fp.Separator) + resolved
+	}
+	resolved, err := archiveRealPath(resolved)
+	if err != nil {
+		return err
+	} else if !fileIsRelative(resolved, dest) {
+		return fmt.Errorf("archive symlink %s points outside of the destination", path)
+	}
+	return os.Symlink(target, path)
+}
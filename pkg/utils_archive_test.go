@@ -0,0 +1,176 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"testing"
+)
+
+func writeTestTarGz(t *testing.T, path string, files map[string]string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, body := range files {
+		hdr := &tar.Header{Name: name, Mode: 0755, Size: int64(len(body)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(body))
+	}
+	tw.Close()
+	gz.Close()
+}
+
+func writeTestZip(t *testing.T, path string, files map[string]string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	zw.Close()
+}
+
+func assertTestFile(t *testing.T, path, expected string) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Errorf("failed to read %s: %s", path, err)
+	} else if string(content) != expected {
+		t.Errorf("expected %s to contain %q, got %q", path, expected, content)
+	}
+}
+
+func TestExtractArchive_StripsComponents(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "dotty-archive")
+	defer os.RemoveAll(tmp)
+
+	cases := map[string]func(*testing.T, string, map[string]string){
+		"tool.tar.gz": writeTestTarGz,
+		"tool.zip":    writeTestZip,
+	}
+	for name, write := range cases {
+		src := fp.Join(tmp, name)
+		write(t, src, map[string]string{
+			"tool-1.0/bin/tool":  "#!/bin/sh\n",
+			"tool-1.0/README.md": "readme",
+		})
+
+		format, ok := archiveFormatFromPath(src)
+		if !ok {
+			t.Fatalf("failed to guess format of %s", name)
+		}
+		dest := fp.Join(tmp, name+".d")
+		if err := extractArchive(src, format, dest, 1); err != nil {
+			t.Fatalf("failed to extract %s: %s", name, err)
+		}
+		assertTestFile(t, fp.Join(dest, "bin", "tool"), "#!/bin/sh\n")
+		assertTestFile(t, fp.Join(dest, "README.md"), "readme")
+	}
+}
+
+func TestExtractArchive_RejectsPathTraversal(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "dotty-archive")
+	defer os.RemoveAll(tmp)
+
+	src := fp.Join(tmp, "evil.tar.gz")
+	writeTestTarGz(t, src, map[string]string{"../evil": "evil"})
+	if err := extractArchive(src, archiveTarGz, fp.Join(tmp, "dest"), 0); err == nil {
+		t.Error("expected archive escaping dest to be rejected")
+	}
+	if exists, _ := pathExists(fp.Join(tmp, "evil"), false); exists {
+		t.Error("archive wrote a file outside of dest")
+	}
+}
+
+func TestExtractArchive_RejectsChainedSymlinks(t *testing.T) {
+	for name, entries := range map[string][]tar.Header{
+		"file through symlinks": {
+			{Name: "a", Linkname: ".", Typeflag: tar.TypeSymlink},
+			{Name: "a/b", Linkname: "..", Typeflag: tar.TypeSymlink},
+			{Name: "a/b/evil", Typeflag: tar.TypeReg},
+		},
+		"symlink through symlinks": {
+			{Name: "a", Linkname: ".", Typeflag: tar.TypeSymlink},
+			{Name: "b", Linkname: "a/..", Typeflag: tar.TypeSymlink},
+		},
+		"file over symlink": {
+			{Name: "sub/a", Linkname: "..", Typeflag: tar.TypeSymlink},
+			{Name: "sub/a/b", Linkname: "../evil", Typeflag: tar.TypeSymlink},
+			{Name: "b", Typeflag: tar.TypeReg},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tmp := t.TempDir()
+			src := fp.Join(tmp, "evil.tar")
+			f, err := os.Create(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tw := tar.NewWriter(f)
+			for i := range entries {
+				entries[i].Mode = 0644
+				if entries[i].Typeflag == tar.TypeReg {
+					entries[i].Size = int64(len("evil"))
+				}
+				if err := tw.WriteHeader(&entries[i]); err != nil {
+					t.Fatal(err)
+				}
+				if entries[i].Typeflag == tar.TypeReg {
+					tw.Write([]byte("evil"))
+				}
+			}
+			tw.Close()
+			f.Close()
+
+			dest := fp.Join(tmp, "dest")
+			err = extractArchive(src, archiveTar, dest, 0)
+			if exists, _ := pathExists(fp.Join(tmp, "evil"), false); exists {
+				t.Error("archive wrote a file outside of dest")
+			}
+			if err != nil {
+				return
+			}
+			// anything that was extracted must stay within dest.
+			fp.Walk(dest, func(path string, info os.FileInfo, err error) error {
+				if real, err := fp.EvalSymlinks(path); err == nil && !fileIsRelative(real, dest) {
+					t.Errorf("extracted %s resolves to %s outside of dest", path, real)
+				}
+				return nil
+			})
+		})
+	}
+}
+
+func TestArchiveFormatFromPath(t *testing.T) {
+	cases := map[string]string{
+		"foo.tar":    archiveTar,
+		"foo.tgz":    archiveTarGz,
+		"foo.tar.gz": archiveTarGz,
+		"foo.tar.xz": archiveTarXz,
+		"foo.txz":    archiveTarXz,
+		"foo.zip":    archiveZip,
+	}
+	for path, expected := range cases {
+		if format, ok := archiveFormatFromPath(path); !ok || format != expected {
+			t.Errorf("expected %s to have format %s, got %s", path, expected, format)
+		}
+	}
+	if _, ok := archiveFormatFromPath("foo.rar"); ok {
+		t.Error("unexpected format for foo.rar")
+	}
+}
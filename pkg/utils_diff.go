@@ -0,0 +1,223 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// the number of unchanged lines shown around each change in a diff.
+const diffContext = 3
+
+// a single line in a diff and how it was changed.
+type diffOp struct {
+	// one of ' ' (unchanged), '-' (removed) or '+' (added)
+	kind byte
+	line string
+}
+
+/**
+ * split text into lines, without their trailing newlines.
+ */
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+/**
+ * Find the shortest sequence of line removals and additions needed to
+ * turn a into b, using the linear space variant of Myers' diff algorithm.
+ */
+func diffLines(a, b []string) []diffOp {
+	return appendDiffOps(make([]diffOp, 0, len(a)+len(b)), a, b)
+}
+
+// append the ops turning a into b onto ops. Rather than remembering every
+// round of the search to walk back through, we find a point the shortest
+// path passes through and recurse on either side of it.
+func appendDiffOps(ops []diffOp, a, b []string) []diffOp {
+	// lines common to the start and end of both sides are always unchanged.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, diffOp{' ', a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-suffix-1] == b[len(b)-suffix-1] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	if len(a) == 0 || len(b) == 0 {
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+	} else {
+		x, y := diffMiddle(a, b)
+		ops = appendDiffOps(ops, a[:x], b[:y])
+		ops = appendDiffOps(ops, a[x:], b[y:])
+	}
+
+	for _, line := range common {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+/**
+ * Find a point (x, y) on the shortest path from the start of a and b to
+ * their ends, by searching forwards from the start and backwards from the
+ * end at the same time until the two searches overlap. Only the furthest
+ * point reached on each diagonal is kept, so this needs linear space.
+ *
+ * a and b must both be non-empty and differ in their first and last lines.
+ */
+func diffMiddle(a, b []string) (int, int) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	forward, backward := make([]int, 2*maxD+2), make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	// the forward and backward diagonals meet on the same round, or the
+	// backward search has to catch up, depending on whether delta is odd.
+	delta := n - m
+	odd := delta%2 != 0
+
+	// diagonals that've run off the edge of a or b, which we stop searching.
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			forward[offset+k] = x
+			if x > n {
+				fEnd += 2
+			} else if y > m {
+				fStart += 2
+			} else if odd {
+				if bk := offset + delta - k; bk >= 0 && bk < len(backward) && backward[bk] != -1 && x >= n-backward[bk] {
+					return x, y
+				}
+			}
+		}
+
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x, y = x+1, y+1
+			}
+			backward[offset+k] = x
+			if x > n {
+				bEnd += 2
+			} else if y > m {
+				bStart += 2
+			} else if !odd {
+				if fk := offset + delta - k; fk >= 0 && fk < len(forward) && forward[fk] != -1 {
+					if fx := forward[fk]; fx >= n-x {
+						return fx, offset + fx - fk
+					}
+				}
+			}
+		}
+	}
+
+	// a and b have nothing in common, so everything is replaced.
+	return n, 0
+}
+
+/**
+ * Generate a unified diff from the text a (at path aName) to the text b
+ * (at path bName). If the two are the same, an empty string is returned.
+ */
+func unifiedDiff(aName, bName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	if strings.IndexByte(a, 0) != -1 || strings.IndexByte(b, 0) != -1 {
+		return fmt.Sprintf("Binary files %s and %s differ\n", aName, bName)
+	}
+
+	ops := diffLines(splitLines(a), splitLines(b))
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+
+	// line numbers in a and b at the start of each op.
+	aLines, bLines := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, op := range ops {
+		aLines[i+1], bLines[i+1] = aLines[i], bLines[i]
+		if op.kind != '+' {
+			aLines[i+1]++
+		}
+		if op.kind != '-' {
+			bLines[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// extend this hunk until there's enough unchanged lines between
+		// this change and the next that they can be shown separately.
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		if end += diffContext; end > len(ops) {
+			end = len(ops)
+		}
+
+		aStart, aLen := aLines[start], aLines[end]-aLines[start]
+		bStart, bLen := bLines[start], bLines[end]-bLines[start]
+		if aLen > 0 {
+			aStart++
+		}
+		if bLen > 0 {
+			bStart++
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+
+	return out.String()
+}
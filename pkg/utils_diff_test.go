@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestUnifiedDiff_SameTextHasNoDiff(t *testing.T) {
+	if diff := unifiedDiff("a", "b", "foo\nbar\n", "foo\nbar\n"); diff != "" {
+		t.Errorf("diff of identical text isn't empty: %s", diff)
+	}
+}
+
+func TestUnifiedDiff_ShowsChangesWithContext(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	b := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n"
+	expected := `--- a
++++ b
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`
+	if diff := unifiedDiff("a", "b", a, b); diff != expected {
+		t.Errorf("diff mismatch: expected != actual, %q != %q", expected, diff)
+	}
+}
+
+func TestUnifiedDiff_SplitsDistantChangesIntoHunks(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n"
+	expected := `--- a
++++ b
+@@ -1,4 +1,4 @@
+-1
++one
+ 2
+ 3
+ 4
+@@ -9,4 +9,4 @@
+ 9
+ 10
+ 11
+-12
++twelve
+`
+	if diff := unifiedDiff("a", "b", a, b); diff != expected {
+		t.Errorf("diff mismatch: expected != actual, %q != %q", expected, diff)
+	}
+}
+
+func TestUnifiedDiff_HandlesEmptyFiles(t *testing.T) {
+	expected := `--- a
++++ b
+@@ -0,0 +1,2 @@
++foo
++bar
+`
+	if diff := unifiedDiff("a", "b", "", "foo\nbar\n"); diff != expected {
+		t.Errorf("diff mismatch: expected != actual, %q != %q", expected, diff)
+	}
+}
+
+func TestDiffLines_LargeDifferentFiles(t *testing.T) {
+	a, b := make([]string, 5000), make([]string, 5000)
+	for i := range a {
+		a[i] = fmt.Sprintf("a%d", i)
+		b[i] = fmt.Sprintf("b%d", i)
+		if i%100 == 0 {
+			a[i], b[i] = "same", "same"
+		}
+	}
+
+	var aOut, bOut []string
+	for _, op := range diffLines(a, b) {
+		if op.kind != '+' {
+			aOut = append(aOut, op.line)
+		}
+		if op.kind != '-' {
+			bOut = append(bOut, op.line)
+		}
+	}
+	if !reflect.DeepEqual(aOut, a) || !reflect.DeepEqual(bOut, b) {
+		t.Error("diff of large files doesn't reproduce both files")
+	}
+}
@@ -0,0 +1,191 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// a single assignment from a dotenv file.
+type dotenvVar struct {
+	key string
+	val string
+}
+
+/**
+ * Parse the contents of a dotenv file, such as:
+ *   # comments and blank lines are ignored.
+ *   export EMAIL=me@example.com
+ *   NAME="Jane Doe" # a comment after a value.
+ *   GREETING="hello ${NAME}\n"
+ *   LITERAL='single quoted values, like $this, are left alone'
+ *
+ * Unquoted and double quoted values have variables substituted into them, as
+ * in expandVariables, from earlier assignments in the file or from lookup.
+ * Double quoted values also support the escapes \n, \t, \", \\ and \$. Quoted
+ * values can span multiple lines. The variables are returned in the order
+ * they're assigned.
+ */
+func parseDotenv(data string, lookup func(string) (string, bool)) ([]dotenvVar, error) {
+	p := dotenvParser{data: strings.ReplaceAll(data, "\r\n", "\n"), line: 1}
+	var vars []dotenvVar
+	assigned := make(map[string]string)
+	lookupAssigned := func(key string) (string, bool) {
+		if val, ok := assigned[key]; ok {
+			return val, true
+		}
+		return lookup(key)
+	}
+
+	for {
+		p.skipSpace(true)
+		if p.done() {
+			return vars, nil
+		}
+		if p.peek() == '#' {
+			p.skipLine()
+			continue
+		}
+
+		line := p.line
+		key := p.readKey()
+		if key == "export" && !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+			p.skipSpace(false)
+			key = p.readKey()
+		}
+		if key == "" {
+			return nil, fmt.Errorf("line %d: expected a variable name", line)
+		}
+		p.skipSpace(false)
+		if p.done() || p.peek() != '=' {
+			return nil, fmt.Errorf("line %d: expected = after %s", line, key)
+		}
+		p.pos++
+		p.skipSpace(false)
+
+		val, err := p.readValue()
+		if err == nil {
+			val, err = expandVariables(val, lookupAssigned)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		assigned[key] = val
+		vars = append(vars, dotenvVar{key, val})
+	}
+}
+
+type dotenvParser struct {
+	data string
+	pos  int
+	line int
+}
+
+func (p *dotenvParser) done() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *dotenvParser) peek() byte {
+	return p.data[p.pos]
+}
+
+// skip whitespace, only moving onto the next line when newlines is true.
+func (p *dotenvParser) skipSpace(newlines bool) {
+	for !p.done() {
+		switch p.peek() {
+		case '\n':
+			if !newlines {
+				return
+			}
+			p.line++
+		case ' ', '\t':
+		default:
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *dotenvParser) skipLine() {
+	for !p.done() && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+func (p *dotenvParser) readKey() string {
+	start := p.pos
+	for !p.done() && (isVariableChar(p.peek()) || (p.pos > start && p.peek() == '.')) {
+		p.pos++
+	}
+	return p.data[start:p.pos]
+}
+
+// read the value of an assignment, ready to have variables substituted into
+// it. literal $ signs, such as those in single quotes, are escaped as $$.
+func (p *dotenvParser) readValue() (string, error) {
+	if p.done() {
+		return "", nil
+	}
+
+	var b strings.Builder
+	switch quote := p.peek(); quote {
+	case '\'', '"':
+		p.pos++
+		for {
+			if p.done() {
+				return "", fmt.Errorf("unterminated %c quoted value", quote)
+			}
+			c := p.peek()
+			p.pos++
+			if c == quote {
+				break
+			}
+			if c == '\n' {
+				p.line++
+			}
+			if quote == '"' && c == '\\' && !p.done() {
+				c = p.peek()
+				p.pos++
+				switch c {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case '$':
+					b.WriteString("$$")
+				case '"', '\\':
+					b.WriteByte(c)
+				default:
+					b.WriteByte('\\')
+					b.WriteByte(c)
+				}
+				continue
+			}
+			if quote == '\'' && c == '$' {
+				b.WriteString("$$")
+				continue
+			}
+			b.WriteByte(c)
+		}
+
+		// only a comment can follow the closing quote.
+		p.skipSpace(false)
+		if !p.done() && p.peek() != '\n' && p.peek() != '#' {
+			return "", fmt.Errorf("unexpected characters after %c quoted value", quote)
+		}
+		p.skipLine()
+		return b.String(), nil
+	}
+
+	start := p.pos
+	for !p.done() && p.peek() != '\n' {
+		// a # only starts a comment after whitespace, so values like urls
+		// with fragments are left intact.
+		if p.peek() == '#' && p.pos > start && (p.data[p.pos-1] == ' ' || p.data[p.pos-1] == '\t') {
+			break
+		}
+		p.pos++
+	}
+	val := strings.TrimSpace(p.data[start:p.pos])
+	p.skipLine()
+	return val, nil
+}
@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv_Syntax(t *testing.T) {
+	data := `# a comment
+export EMAIL=me@example.com
+
+NAME = "Jane Doe" # trailing comment
+GREETING="hello ${NAME}\nvia \$EMAIL"
+LITERAL='$NAME stays'
+URL=https://example.com/#fragment
+EMPTY=
+HOME_DIR=$HOME/foo
+MULTI="line 1
+line 2"
+`
+	vars, err := parseDotenv(data, testExpandLookup)
+	if err != nil {
+		t.Fatalf("failed to parse dotenv: %s", err)
+	}
+
+	expected := []dotenvVar{
+		{"EMAIL", "me@example.com"},
+		{"NAME", "Jane Doe"},
+		{"GREETING", "hello Jane Doe\nvia $EMAIL"},
+		{"LITERAL", "$NAME stays"},
+		{"URL", "https://example.com/#fragment"},
+		{"EMPTY", ""},
+		{"HOME_DIR", "/home/foo/foo"},
+		{"MULTI", "line 1\nline 2"},
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("expected variables %v, got %v", expected, vars)
+	}
+}
+
+func TestParseDotenv_Errors(t *testing.T) {
+	testCases := map[string]string{
+		"FOO":                       "line 1: expected = after FOO",
+		"FOO=bar\n=baz":             "line 2: expected a variable name",
+		`FOO="bar`:                  "line 1: unterminated",
+		`FOO="bar" baz`:             "line 1: unexpected characters",
+		"FOO=bar\nBAR=$UNDEFINED_X": "line 2: undefined variable: UNDEFINED_X",
+	}
+	for data, expected := range testCases {
+		if _, err := parseDotenv(data, testExpandLookup); err == nil {
+			t.Errorf("expected parsing %q to fail", data)
+		} else if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error parsing %q to contain %q, got %q", data, expected, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// the permissions given to files created by directives that edit files.
+const editFileMode = 0644
+
+// split the contents of a file into lines, alongside whether the file ended
+// with a newline so it can be joined back together exactly as it was.
+func splitFileLines(content string) ([]string, bool) {
+	if content == "" {
+		return []string{}, false
+	}
+	eol := strings.HasSuffix(content, "\n")
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n"), eol
+}
+
+func joinFileLines(lines []string, eol bool) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	res := strings.Join(lines, "\n")
+	if eol {
+		res += "\n"
+	}
+	return res
+}
+
+func linesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * Read the file at path and pass its lines through edit, returning the
+ * contents of the file before and after the edit. A file that doesn't
+ * exist is edited as if it were empty.
+ *
+ * The file is kept byte for byte when edit makes no changes. Otherwise the
+ * edited file always ends with a newline.
+ */
+func editedFile(path string, edit func(lines []string) ([]string, error)) (before, after string, exists bool, err error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", false, err
+	}
+	exists = err == nil
+	before = string(bytes)
+
+	lines, eol := splitFileLines(before)
+	edited, err := edit(append([]string(nil), lines...))
+	if err != nil {
+		return before, before, exists, err
+	}
+	if !linesEqual(lines, edited) {
+		eol = true
+	}
+	return before, joinFileLines(edited, eol), exists, nil
+}
+
+// apply edit to the file at path, writing it back only when it's changed.
+// when create is false the file won't be created if it doesn't exist.
+func editFile(ops fileOps, path string, create bool, edit func(lines []string) ([]string, error)) (bool, error) {
+	before, after, exists, err := editedFile(path, edit)
+	if err != nil || before == after {
+		return false, err
+	}
+	if !exists && !create {
+		return false, fmt.Errorf("file doesn't exist")
+	}
+	return true, ops.writeFile(path, []byte(after), editFileMode)
+}
+
+// write out how edit would change the file at path.
+func diffEditFile(w io.Writer, path string, edit func(lines []string) ([]string, error)) {
+	if before, after, _, err := editedFile(path, edit); err == nil {
+		fmt.Fprint(w, unifiedDiff(path, path, before, after))
+	}
+}
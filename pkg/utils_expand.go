@@ -0,0 +1,175 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/drone/envsubst"
+)
+
+/**
+ * Substitute the variables in str with their values from lookup. Both $VAR
+ * and ${VAR} are supported, along with the bash style parameter expansions:
+ *   ${VAR:-default} substitutes default when VAR is unset or empty.
+ *   ${VAR:?message} fails with message when VAR is unset or empty.
+ *   ${VAR:+other} substitutes other only when VAR is set and not empty.
+ *   ${VAR/old/new}, ${VAR//old/new} replace the first or every old with new.
+ *   ${VAR:offset}, ${VAR:offset:length} substitute a substring of VAR.
+ *   ${VAR#prefix}, ${VAR%suffix} remove a prefix or suffix from VAR.
+ *   ${VAR,,}, ${VAR^^} convert VAR to lower or upper case.
+ *   ${#VAR} substitutes the length of VAR.
+ *   ${VAR[@]} substitutes VAR, see Context.evalPaths for iterating over lists.
+ * Like in bash, leaving out the colon (such as ${VAR-default}) only checks
+ * whether VAR is set. A literal $ can be written as $$. Referencing a variable
+ * that isn't set, without giving a default for it, is an error.
+ */
+func expandVariables(str string, lookup func(string) (string, bool)) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		var next byte
+		if i+1 < len(str) {
+			next = str[i+1]
+		}
+
+		switch {
+		case c == '$' && next == '$':
+			b.WriteByte('$')
+			i++
+		case c == '$' && next == '{':
+			end := matchingBrace(str, i+2)
+			if end == -1 {
+				return "", fmt.Errorf("bad substitution: unterminated %s", str[i:])
+			}
+			val, err := expandParameter(str[i+2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(val)
+			i = end
+		case c == '$' && isVariableStart(next):
+			j := i + 1
+			for j < len(str) && isVariableChar(str[j]) {
+				j++
+			}
+			val, ok := lookup(str[i+1 : j])
+			if !ok {
+				return "", fmt.Errorf("undefined variable: %s", str[i+1:j])
+			}
+			b.WriteString(val)
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+func isVariableStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isVariableChar(c byte) bool {
+	return isVariableStart(c) || ('0' <= c && c <= '9')
+}
+
+// the index of the } closing a ${ just before start, or -1 if it isn't closed.
+func matchingBrace(str string, start int) int {
+	depth := 1
+	for i := start; i < len(str); i++ {
+		switch {
+		case str[i] == '$' && i+1 < len(str) && str[i+1] == '{':
+			depth++
+			i++
+		case str[i] == '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// operators that substitute a word depending on whether a variable is set.
+// the word is itself expanded, but only when it's actually used.
+var expansionDefaultOperators = []string{":-", ":=", ":?", ":+", "-", "=", "?", "+"}
+
+// escapes the values substituted into the arguments of a ${VAR/old/new}.
+var expansionReplaceEscaper = strings.NewReplacer(`\`, `\\`, "/", `\/`)
+
+// expand the body of a ${...} substitution.
+func expandParameter(body string, lookup func(string) (string, bool)) (string, error) {
+	nameStart := 0
+	if strings.HasPrefix(body, "#") && len(body) > 1 {
+		// ${#VAR}, the length of VAR.
+		nameStart = 1
+	}
+	nameEnd := nameStart
+	for nameEnd < len(body) && isVariableChar(body[nameEnd]) {
+		nameEnd++
+	}
+	name, rest := body[nameStart:nameEnd], body[nameEnd:]
+	if name == "" || !isVariableStart(name[0]) || (nameStart > 0 && rest != "") {
+		return "", fmt.Errorf("bad substitution: ${%s}", body)
+	}
+	val, set := lookup(name)
+
+	for _, op := range expansionDefaultOperators {
+		if !strings.HasPrefix(rest, op) {
+			continue
+		}
+		word := rest[len(op):]
+		present := set
+		if op[0] == ':' {
+			present = set && val != ""
+		}
+
+		switch op[len(op)-1] {
+		case '-', '=':
+			if present {
+				return val, nil
+			}
+			return expandVariables(word, lookup)
+		case '+':
+			if !present {
+				return "", nil
+			}
+			return expandVariables(word, lookup)
+		case '?':
+			if present {
+				return val, nil
+			}
+			msg, err := expandVariables(word, lookup)
+			if err != nil || msg == "" {
+				msg = "parameter null or not set"
+			}
+			return "", fmt.Errorf("%s: %s", name, msg)
+		}
+	}
+
+	if !set {
+		return "", fmt.Errorf("undefined variable: %s", name)
+	}
+	if (rest == "" || rest == "[@]") && nameStart == 0 {
+		// outside of paths, lists are substituted joined like in the environment.
+		return val, nil
+	}
+	// leave the string manipulations (substrings, replacements etc.) to
+	// envsubst, once any variables in their arguments have been expanded.
+	argLookup := lookup
+	if strings.HasPrefix(rest, "/") {
+		argLookup = func(name string) (string, bool) {
+			val, ok := lookup(name)
+			return expansionReplaceEscaper.Replace(val), ok
+		}
+	}
+	rest, err := expandVariables(rest, argLookup)
+	if err != nil {
+		return "", err
+	}
+	res, err := envsubst.Eval("${"+body[:nameEnd]+rest+"}", func(string) string { return val })
+	if err != nil {
+		return "", fmt.Errorf("%s: ${%s}", err, body)
+	}
+	return res, nil
+}
@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func testExpandLookup(name string) (string, bool) {
+	val, ok := map[string]string{
+		"HOME":  "/home/foo",
+		"XDG":   "/home/foo/.config",
+		"EMPTY": "",
+		"NAME":  "hello-world",
+	}[name]
+	return val, ok
+}
+
+func TestExpandVariables_Substitutes(t *testing.T) {
+	testCases := map[string]string{
+		"$HOME/.bashrc":                "/home/foo/.bashrc",
+		"${XDG}/nvim":                  "/home/foo/.config/nvim",
+		"$NAME.txt":                    "hello-world.txt",
+		"${UNSET:-$HOME/.config}/nvim": "/home/foo/.config/nvim",
+		"${EMPTY:-default}":            "default",
+		"${XDG:-/etc}":                 "/home/foo/.config",
+		"${NAME/-/_}":                  "hello_world",
+		"${NAME/-/$XDG/}":              "hello/home/foo/.config/world",
+		"${NAME//o/0}":                 "hell0-w0rld",
+		"${NAME:0:5}":                  "hello",
+		"${NAME:6}":                    "world",
+		"${NAME#hello-}":               "world",
+		"${NAME^^}":                    "HELLO-WORLD",
+		"${#NAME}":                     "11",
+		"cost $$5 and $$HOME":          "cost $5 and $HOME",
+		"a $ b":                        "a $ b",
+		`\\server\share\$NAME`:         `\\server\share\hello-world`,
+		"${HOME:?home must be set}":    "/home/foo",
+		"${HOME:+set}${UNSET:+unset}":  "set",
+		"${EMPTY-default}":             "",
+	}
+
+	for str, expected := range testCases {
+		res, err := expandVariables(str, testExpandLookup)
+		if err != nil {
+			t.Errorf("failed to expand %q: %s", str, err)
+		} else if res != expected {
+			t.Errorf("expected %q to expand to %q, got %q", str, expected, res)
+		}
+	}
+}
+
+func TestExpandVariables_FailsOnUndefinedVariables(t *testing.T) {
+	testCases := map[string]string{
+		"$UNSET/.config":            "undefined variable: UNSET",
+		"${UNSET}/.config":          "undefined variable: UNSET",
+		"${HOME:0:$UNSET}":          "undefined variable: UNSET",
+		"${EMPTY:-$UNSET}":          "undefined variable: UNSET",
+		"${UNSET:?must set UNSET}":  "must set UNSET",
+		"${EMPTY:?EMPTY for $NAME}": "EMPTY for hello-world",
+		"${HOME":                    "bad substitution",
+		"${}":                       "bad substitution",
+	}
+	for str, expected := range testCases {
+		if _, err := expandVariables(str, testExpandLookup); err == nil {
+			t.Errorf("expected expanding %q to fail", str)
+		} else if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error expanding %q to mention %q, got %q", str, expected, err)
+		}
+	}
+
+	// defaults are only checked when they're used.
+	if _, err := expandVariables("${HOME:-$UNSET}", testExpandLookup); err != nil {
+		t.Errorf("unused default was expanded: %s", err)
+	}
+}
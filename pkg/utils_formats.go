@@ -0,0 +1,689 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	fp "path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+/**
+ * Reading and writing structured config files (JSON, YAML, TOML and INI)
+ * as a tree of orderedMaps, slices and scalar values.
+ *
+ * Maps keep the order of their keys so rewriting a file doesn't shuffle
+ * everything around. Numbers are always int64 or float64 so values read
+ * from different formats can be compared.
+ */
+
+// the structured file formats we can read and write.
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+	formatTOML = "toml"
+	formatINI  = "ini"
+)
+
+// guess the format of a file from its extension.
+func formatFromPath(path string) (string, bool) {
+	switch strings.ToLower(strings.TrimPrefix(fp.Ext(path), ".")) {
+	case "json":
+		return formatJSON, true
+	case "yaml", "yml":
+		return formatYAML, true
+	case "toml":
+		return formatTOML, true
+	case "ini", "cfg", "conf":
+		return formatINI, true
+	}
+	return "", false
+}
+
+// a map that remembers the order keys were added to it.
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{keys: make([]string, 0), values: make(map[string]interface{})}
+}
+
+func (m *orderedMap) get(key string) (interface{}, bool) {
+	val, ok := m.values[key]
+	return val, ok
+}
+
+func (m *orderedMap) set(key string, val interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = val
+}
+
+func (m *orderedMap) remove(key string) bool {
+	if _, ok := m.values[key]; !ok {
+		return false
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// convert the integer types the decoders produce into int64.
+func normalizeNumber(val interface{}) interface{} {
+	switch num := val.(type) {
+	case int:
+		return int64(num)
+	case int32:
+		return int64(num)
+	case uint64:
+		return int64(num)
+	case float32:
+		return float64(num)
+	case json.Number:
+		if i, err := num.Int64(); err == nil {
+			return i
+		}
+		if f, err := num.Float64(); err == nil {
+			return f
+		}
+	}
+	return val
+}
+
+// parse data in format into a tree. empty files are read as an empty map.
+func decodeFormat(format string, data []byte) (*orderedMap, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return newOrderedMap(), nil
+	}
+
+	var tree interface{}
+	var err error
+	switch format {
+	case formatJSON:
+		tree, err = decodeJSON(data)
+	case formatYAML:
+		tree, err = decodeYAML(data)
+	case formatTOML:
+		tree, err = decodeTOML(data)
+	case formatINI:
+		tree, err = decodeINI(data)
+	default:
+		return nil, fmt.Errorf("unknown file format %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if m, ok := tree.(*orderedMap); ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("%s file must contain a map at the top level, not %T", format, tree)
+}
+
+// serialise tree in format. template is the original contents of the file
+// being written, used to match its indentation where possible.
+func encodeFormat(format string, tree *orderedMap, template []byte) ([]byte, error) {
+	switch format {
+	case formatJSON:
+		return encodeJSON(tree, detectIndent(template, "  ")), nil
+	case formatYAML:
+		return yaml.Marshal(toYAML(tree))
+	case formatTOML:
+		var buf bytes.Buffer
+		err := toml.NewEncoder(&buf).Encode(toTOML(tree))
+		return buf.Bytes(), err
+	case formatINI:
+		return encodeINI(tree, detectINISeparator(template))
+	}
+	return nil, fmt.Errorf("unknown file format %s", format)
+}
+
+/**
+ * Describe what rewriting data in format would lose, besides its formatting.
+ * None of the formats keep their comments and TOML files are always written
+ * with their keys sorted.
+ */
+func formatLosses(format string, data []byte) []string {
+	var comments bool
+	switch format {
+	case formatJSON:
+		_, comments = stripJSONComments(data)
+	case formatYAML:
+		comments = yamlHasComments(data)
+	case formatTOML:
+		comments = tomlHasComments(data)
+	case formatINI:
+		comments = iniHasComments(data)
+	}
+
+	var losses []string
+	if comments {
+		losses = append(losses, "comments")
+	}
+	if format == formatTOML && !tomlKeysInOrder(data) {
+		losses = append(losses, "key order")
+	}
+	return losses
+}
+
+// find the indentation of the first indented line in data.
+func detectIndent(data []byte, def string) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) != len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return def
+}
+
+// JSON
+
+/**
+ * Remove any comments and trailing commas from data, letting us read the
+ * JSON with comments files used by editors like VS Code. Returns whether
+ * any comments were found, since writing the file back will lose them.
+ */
+func stripJSONComments(data []byte) ([]byte, bool) {
+	var res bytes.Buffer
+	found := false
+	inString, escaped := false, false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			res.WriteByte(c)
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		if c == '/' && i+1 < len(data) && data[i+1] == '/' {
+			found = true
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			res.WriteByte('\n')
+			continue
+		} else if c == '/' && i+1 < len(data) && data[i+1] == '*' {
+			found = true
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end == -1 {
+				break
+			}
+			i += end + 3
+			continue
+		}
+
+		if c == '"' {
+			inString = true
+		}
+		res.WriteByte(c)
+	}
+
+	// drop any commas directly before the end of an object or array.
+	stripped, out := res.Bytes(), make([]byte, 0, res.Len())
+	inString, escaped = false, false
+	for i, c := range stripped {
+		if inString {
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+		} else if c == '"' {
+			inString = true
+		} else if c == ',' {
+			next := bytes.TrimLeft(stripped[i+1:], " \t\r\n")
+			if len(next) > 0 && (next[0] == '}' || next[0] == ']') {
+				continue
+			}
+		}
+		out = append(out, c)
+	}
+	return out, found
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	data, _ = stripJSONComments(data)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	val, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the end of the JSON value")
+	}
+	return val, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		m := newOrderedMap()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			val, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			m.set(key.(string), val)
+		}
+		_, err := dec.Token()
+		return m, err
+	case json.Delim('['):
+		arr := make([]interface{}, 0)
+		for dec.More() {
+			val, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		_, err := dec.Token()
+		return arr, err
+	}
+	return normalizeNumber(tok), nil
+}
+
+func encodeJSON(tree *orderedMap, indent string) []byte {
+	var buf bytes.Buffer
+	encodeJSONValue(&buf, tree, indent, 0)
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func encodeJSONValue(buf *bytes.Buffer, val interface{}, indent string, depth int) {
+	newline := func(depth int) {
+		buf.WriteByte('\n')
+		buf.WriteString(strings.Repeat(indent, depth))
+	}
+
+	switch v := val.(type) {
+	case *orderedMap:
+		if len(v.keys) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteByte('{')
+		for i, key := range v.keys {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+			newline(depth + 1)
+			encodeJSONScalar(buf, key)
+			buf.WriteString(": ")
+			encodeJSONValue(buf, v.values[key], indent, depth+1)
+		}
+		newline(depth)
+		buf.WriteByte('}')
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+			newline(depth + 1)
+			encodeJSONValue(buf, item, indent, depth+1)
+		}
+		newline(depth)
+		buf.WriteByte(']')
+	default:
+		encodeJSONScalar(buf, v)
+	}
+}
+
+func encodeJSONScalar(buf *bytes.Buffer, val interface{}) {
+	var scalar bytes.Buffer
+	enc := json.NewEncoder(&scalar)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(val); err != nil {
+		// values from other formats (such as TOML dates) may not be valid
+		// JSON, so we fallback to writing them as strings.
+		enc.Encode(fmt.Sprint(val))
+	}
+	buf.Write(bytes.TrimSuffix(scalar.Bytes(), []byte("\n")))
+}
+
+// YAML
+
+func decodeYAML(data []byte) (interface{}, error) {
+	var tree yaml.MapSlice
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	return fromYAML(tree), nil
+}
+
+// matches a line ending in a block scalar indicator, such as key: |-
+var yamlBlockScalarRegexp = regexp.MustCompile(`(^|\s)[|>][-+1-9]*$`)
+
+// assert whether the YAML in data contains any comments. a # only starts a
+// comment at the beginning of a line or after whitespace, outside of quotes
+// and block scalars.
+func yamlHasComments(data []byte) bool {
+	blockIndent := -1
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		indent := len(line) - len(trimmed)
+		if blockIndent != -1 {
+			if strings.TrimSpace(line) == "" || indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+
+		var quote byte
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			if quote != 0 {
+				if c == '\\' && quote == '"' {
+					i++
+				} else if c == quote {
+					quote = 0
+				}
+			} else if c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+				return true
+			} else if c == '"' || c == '\'' {
+				// quotes only matter at the start of a value, not in don't.
+				if prefix := strings.TrimRight(line[:i], " \t"); prefix == "" || strings.ContainsAny(prefix[len(prefix)-1:], ":-[{,?") {
+					quote = c
+				}
+			}
+		}
+		if yamlBlockScalarRegexp.MatchString(strings.TrimRight(line, " \t\r")) {
+			blockIndent = indent
+		}
+	}
+	return false
+}
+
+func fromYAML(val interface{}) interface{} {
+	switch v := val.(type) {
+	case yaml.MapSlice:
+		m := newOrderedMap()
+		for _, item := range v {
+			key, ok := item.Key.(string)
+			if !ok {
+				key = fmt.Sprint(item.Key)
+			}
+			m.set(key, fromYAML(item.Value))
+		}
+		return m
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, item := range v {
+			arr[i] = fromYAML(item)
+		}
+		return arr
+	}
+	return normalizeNumber(val)
+}
+
+func toYAML(val interface{}) interface{} {
+	switch v := val.(type) {
+	case *orderedMap:
+		m := make(yaml.MapSlice, 0, len(v.keys))
+		for _, key := range v.keys {
+			m = append(m, yaml.MapItem{Key: key, Value: toYAML(v.values[key])})
+		}
+		return m
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, item := range v {
+			arr[i] = toYAML(item)
+		}
+		return arr
+	}
+	return val
+}
+
+// TOML
+
+func decodeTOML(data []byte) (interface{}, error) {
+	var tree map[string]interface{}
+	if _, err := toml.Decode(string(data), &tree); err != nil {
+		return nil, err
+	}
+	return fromTOML(tree), nil
+}
+
+// assert whether the TOML in data contains any comments, which start with
+// a # anywhere outside of a string.
+func tomlHasComments(data []byte) bool {
+	s := string(data)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], `"""`), strings.HasPrefix(s[i:], "'''"):
+			end := strings.Index(s[i+3:], s[i:i+3])
+			if end == -1 {
+				return false
+			}
+			i += end + 5
+		case s[i] == '"':
+			for i++; i < len(s) && s[i] != '"' && s[i] != '\n'; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case s[i] == '\'':
+			for i++; i < len(s) && s[i] != '\'' && s[i] != '\n'; i++ {
+			}
+		case s[i] == '#':
+			return true
+		}
+	}
+	return false
+}
+
+// assert whether the keys of the TOML in data are already in the order
+// they'd be written in.
+func tomlKeysInOrder(data []byte) bool {
+	var tree map[string]interface{}
+	md, err := toml.Decode(string(data), &tree)
+	if err != nil {
+		return true
+	}
+	encoded, err := encodeFormat(formatTOML, fromTOML(tree).(*orderedMap), nil)
+	if err != nil {
+		return true
+	}
+	var encodedTree map[string]interface{}
+	encodedMd, err := toml.Decode(string(encoded), &encodedTree)
+	return err != nil || reflect.DeepEqual(md.Keys(), encodedMd.Keys())
+}
+
+func fromTOML(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		// TOML encoders sort keys, so there's no order worth keeping.
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		m := newOrderedMap()
+		for _, key := range keys {
+			m.set(key, fromTOML(v[key]))
+		}
+		return m
+	case []map[string]interface{}:
+		arr := make([]interface{}, len(v))
+		for i, item := range v {
+			arr[i] = fromTOML(item)
+		}
+		return arr
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, item := range v {
+			arr[i] = fromTOML(item)
+		}
+		return arr
+	}
+	return normalizeNumber(val)
+}
+
+func toTOML(val interface{}) interface{} {
+	switch v := val.(type) {
+	case *orderedMap:
+		m := make(map[string]interface{}, len(v.keys))
+		for _, key := range v.keys {
+			m[key] = toTOML(v.values[key])
+		}
+		return m
+	case []interface{}:
+		// arrays of tables have to be typed as such to be encoded.
+		tables := make([]map[string]interface{}, 0, len(v))
+		arr := make([]interface{}, len(v))
+		for i, item := range v {
+			arr[i] = toTOML(item)
+			if table, ok := arr[i].(map[string]interface{}); ok {
+				tables = append(tables, table)
+			}
+		}
+		if len(v) > 0 && len(tables) == len(v) {
+			return tables
+		}
+		return arr
+	}
+	return val
+}
+
+// INI
+
+/**
+ * Parse an INI file into a map of sections, with any keys before the
+ * first section at the top level. Every value is a string and comments
+ * are discarded.
+ */
+func decodeINI(data []byte) (interface{}, error) {
+	root := newOrderedMap()
+	section := root
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", i+1)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if existing, ok := root.get(name); ok {
+				if section, ok = existing.(*orderedMap); !ok {
+					return nil, fmt.Errorf("line %d: section %s has the same name as a key", i+1, name)
+				}
+			} else {
+				section = newOrderedMap()
+				root.set(name, section)
+			}
+			continue
+		}
+
+		sep := strings.IndexAny(line, "=:")
+		if sep == -1 {
+			return nil, fmt.Errorf("line %d: expected a key and value", i+1)
+		}
+		section.set(strings.TrimSpace(line[:sep]), strings.TrimSpace(line[sep+1:]))
+	}
+	return root, nil
+}
+
+// assert whether the INI in data contains any comments, which are lines
+// starting with a ; or #.
+func iniHasComments(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && strings.ContainsAny(line[:1], ";#") {
+			return true
+		}
+	}
+	return false
+}
+
+// find whether the keys in an INI file have spaces around their equals.
+func detectINISeparator(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.ContainsAny(line[:1], ";#[") {
+			continue
+		}
+		if i := strings.IndexByte(line, '='); i != -1 {
+			if i > 0 && line[i-1] == ' ' {
+				return " = "
+			}
+			return "="
+		}
+	}
+	return " = "
+}
+
+func encodeINI(tree *orderedMap, separator string) ([]byte, error) {
+	var buf bytes.Buffer
+	writeKeys := func(m *orderedMap, section string) error {
+		for _, key := range m.keys {
+			switch val := m.values[key].(type) {
+			case *orderedMap:
+				if section != "" {
+					return fmt.Errorf("INI sections can't be nested, found %s in %s", key, section)
+				}
+			case []interface{}:
+				return fmt.Errorf("INI files can't contain lists, found one at %s", key)
+			default:
+				fmt.Fprintf(&buf, "%s%s%v\n", key, separator, val)
+			}
+		}
+		return nil
+	}
+
+	if err := writeKeys(tree, ""); err != nil {
+		return nil, err
+	}
+	for _, key := range tree.keys {
+		if section, ok := tree.values[key].(*orderedMap); ok {
+			if buf.Len() != 0 {
+				buf.WriteByte('\n')
+			}
+			fmt.Fprintf(&buf, "[%s]\n", key)
+			if err := writeKeys(section, key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
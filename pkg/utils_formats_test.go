@@ -0,0 +1,91 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeJSON_KeepsKeyOrder(t *testing.T) {
+	data := "{\n  \"b\": 1,\n  \"a\": {\n    \"d\": [\n      true\n    ],\n    \"c\": \"x\"\n  }\n}\n"
+	tree, err := decodeFormat(formatJSON, []byte(data))
+	if err != nil {
+		t.Fatalf("failed to decode json: %s", err)
+	}
+	if res, _ := encodeFormat(formatJSON, tree, []byte(data)); string(res) != data {
+		t.Errorf("expected json to round trip unchanged, got %q", res)
+	}
+}
+
+func TestDecodeJSON_AcceptsCommentsAndTrailingCommas(t *testing.T) {
+	data := `{
+  // a comment with "quotes"
+  "a": "http://foo", /* inline */
+  "b": [1, 2,],
+}`
+	tree, err := decodeFormat(formatJSON, []byte(data))
+	if err != nil {
+		t.Fatalf("failed to decode json: %s", err)
+	}
+	if val, _ := tree.get("a"); val != "http://foo" {
+		t.Errorf("expected string containing // to be kept, got %v", val)
+	}
+	if val, _ := tree.get("b"); len(val.([]interface{})) != 2 {
+		t.Errorf("expected list with trailing comma to be read, got %v", val)
+	}
+}
+
+func TestDecodeINI_RoundTrips(t *testing.T) {
+	data := "top=1\n\n[core]\nname=foo\n\n[ui]\ncolor=auto\n"
+	tree, err := decodeFormat(formatINI, []byte("; comment\n"+data))
+	if err != nil {
+		t.Fatalf("failed to decode ini: %s", err)
+	}
+	if res, _ := encodeFormat(formatINI, tree, []byte(data)); string(res) != data {
+		t.Errorf("expected ini to round trip, got %q", res)
+	}
+}
+
+func TestDecodeFormat_NormalizesNumbers(t *testing.T) {
+	for _, format := range []struct{ name, data string }{
+		{formatJSON, `{"a": 1}`},
+		{formatYAML, "a: 1"},
+		{formatTOML, "a = 1"},
+	} {
+		tree, err := decodeFormat(format.name, []byte(format.data))
+		if err != nil {
+			t.Fatalf("failed to decode %s: %s", format.name, err)
+		}
+		if val, _ := tree.get("a"); val != int64(1) {
+			t.Errorf("expected %s number to be an int64, got %T", format.name, val)
+		}
+	}
+}
+
+func TestFormatLosses(t *testing.T) {
+	testCases := []struct {
+		format, data string
+		losses       []string
+	}{
+		{formatJSON, `{"a": "http://foo"}`, nil},
+		{formatJSON, "{\n  // comment\n  \"a\": 1\n}", []string{"comments"}},
+		{formatYAML, "a: http://foo#bar\nb: 'quoted # text'\nc: don't # comment\n", []string{"comments"}},
+		{formatYAML, "a: http://foo#bar\nb: \"quoted # text\"\nc: don't\n", nil},
+		{formatYAML, "# comment\na: 1\n", []string{"comments"}},
+		{formatYAML, "a: |\n  # not a comment\n  text\nb: 1\n", nil},
+		{formatYAML, "a: |\n  text\nb: 1 # comment\n", []string{"comments"}},
+		{formatTOML, "a = 1\nb = \"# not a comment\"\nc = '''\n# still not\n'''\n", nil},
+		{formatTOML, "a = 1 # comment\n", []string{"comments"}},
+		{formatTOML, "b = 1\na = 2\n", []string{"key order"}},
+		{formatTOML, "# comment\nb = 1\na = 2\n", []string{"comments", "key order"}},
+		{formatTOML, "a = 1\n\n[b]\nc = 2\n", nil},
+		{formatINI, "[core]\nname=foo#bar\n", nil},
+		{formatINI, "; comment\n[core]\nname=foo\n", []string{"comments"}},
+		{formatINI, "[core]\n  # comment\nname=foo\n", []string{"comments"}},
+	}
+
+	for _, test := range testCases {
+		if losses := formatLosses(test.format, []byte(test.data)); !reflect.DeepEqual(losses, test.losses) {
+			t.Errorf("expected rewriting %s %q to lose %v, got %v", test.format, test.data, test.losses, losses)
+		}
+	}
+}
@@ -1,8 +1,14 @@
 package pkg
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	fp "path/filepath"
 	"syscall"
 
 	"github.com/rs/zerolog/log"
@@ -62,3 +68,137 @@ func FindExistingFile(paths ...string) (string, error) {
 
 	return "", fmt.Errorf("Unable to find any existing file")
 }
+
+/**
+ * assert whether the files at a and b have the same contents.
+ */
+func filesEqual(a, b string) (bool, error) {
+	aBytes, err := ioutil.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+	bBytes, err := ioutil.ReadFile(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(aBytes, bBytes), nil
+}
+
+/**
+ * the hex encoded sha256 checksum of the file at path.
+ */
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+/**
+ * copy the regular file at src to dest, preserving its permissions.
+ */
+func copyFile(src, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+/**
+ * move the file at src to dest. When the two are on different devices
+ * the file is copied across and then src is removed.
+ */
+func moveFile(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	} else if linkErr, ok := err.(*os.LinkError); !ok || linkErr.Err != syscall.EXDEV {
+		return err
+	}
+
+	if err := os.MkdirAll(fp.Dir(dest), 0744); err != nil {
+		return err
+	}
+	if err := copyFile(src, dest); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// the permission bits of a file mode that chmod can change.
+const chmodBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+/**
+ * make sure the file at path (following symlinks) has the permissions mode,
+ * changing them if they've drifted. Returns false if the permissions are wrong
+ * and couldn't be changed.
+ */
+func reconcileMode(ops fileOps, path string, mode os.FileMode) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Error().Str("path", path).
+			Str("error", err.Error()).
+			Msg("Failed to stat path to change permissions")
+		return false
+	}
+
+	current := info.Mode() & chmodBits
+	if current == mode {
+		log.Trace().Str("path", path).
+			Str("mode", formatMode(mode)).
+			Msg("Permissions already up to date")
+		return true
+	}
+
+	log.Info().Str("path", path).
+		Str("from", formatMode(current)).
+		Str("to", formatMode(mode)).
+		Msg("Changing permissions")
+	if err := ops.chmod(path, mode); err != nil {
+		log.Error().Str("path", path).
+			Str("mode", formatMode(mode)).
+			Str("error", err.Error()).
+			Msg("Failed to change permissions")
+		return false
+	}
+	return true
+}
+
+/**
+ * format a file mode in the octal notation that chmod accepts.
+ */
+func formatMode(mode os.FileMode) string {
+	res := uint32(mode & os.ModePerm)
+	if mode&os.ModeSetuid != 0 {
+		res |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		res |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		res |= 0o1000
+	}
+	return fmt.Sprintf("%04o", res)
+}
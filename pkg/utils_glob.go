@@ -0,0 +1,213 @@
+package pkg
+
+import (
+	"bufio"
+	"os"
+	fp "path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// the name of the file listing paths that globs should never match.
+const ignoreFileName = ".dottyignore"
+
+/**
+ * Assert whether path matches pattern. This behaves like filepath.Match
+ * except a path component of ** in pattern matches zero or more directories.
+ *
+ *  globMatch("bin/**", "bin/foo/bar")      ;; true
+ *  globMatch("**" + "/*.sh", "foo/bar.sh") ;; true
+ */
+func globMatch(pattern, path string) (bool, error) {
+	return globMatchParts(splitPath(pattern), splitPath(path))
+}
+
+func globMatchParts(pattern, path []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// try to match the rest of pattern from every remaining depth.
+			for i := 0; i <= len(path); i++ {
+				if ok, err := globMatchParts(pattern[1:], path[i:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+
+		if len(path) == 0 {
+			return false, nil
+		}
+		if ok, err := fp.Match(pattern[0], path[0]); !ok || err != nil {
+			return false, err
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0, nil
+}
+
+// split path into its components, ignoring any empty ones.
+func splitPath(path string) []string {
+	parts := strings.Split(fp.ToSlash(path), "/")
+	res := parts[:0]
+	for i, part := range parts {
+		// keep the leading empty component of absolute paths so they
+		// only match absolute patterns.
+		if part != "" || i == 0 && len(parts) > 1 {
+			res = append(res, part)
+		}
+	}
+	return res
+}
+
+/**
+ * Get the leading directory of pattern that doesn't contain any glob
+ * characters. Every path pattern can match is contained within it.
+ */
+func globBase(pattern string) string {
+	parts := strings.Split(pattern, string(fp.Separator))
+	for i, part := range parts {
+		if strings.ContainsAny(part, "*?[\\") {
+			base := strings.Join(parts[:i], string(fp.Separator))
+			if base == "" && fp.IsAbs(pattern) {
+				return string(fp.Separator)
+			}
+			return base
+		}
+	}
+	return fp.Dir(pattern)
+}
+
+/**
+ * Expand pattern into all the files it matches. Patterns without a **
+ * component are handed to filepath.Glob, others walk the tree under the
+ * base of the pattern and only return files (not directories). Ignore
+ * files are never matched, since they only describe the files around them.
+ */
+func glob(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		globs, err := fp.Glob(pattern)
+		matches := globs[:0]
+		for _, path := range globs {
+			if fp.Base(path) != ignoreFileName {
+				matches = append(matches, path)
+			}
+		}
+		return matches, err
+	}
+
+	matches := make([]string, 0)
+	err := fp.Walk(globBase(pattern), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || info.Name() == ignoreFileName {
+			return nil
+		}
+		if ok, err := globMatch(pattern, path); err != nil {
+			return err
+		} else if ok {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	sort.Strings(matches)
+	return matches, err
+}
+
+/**
+ * Assert whether the path rel (relative to some directory) matches any of the
+ * gitignore like patterns. Patterns without a slash match any component of rel,
+ * while patterns containing one (or with a leading slash) match rel, or one of
+ * its parent directories, from the start.
+ */
+func globMatchesAny(patterns []string, rel string) bool {
+	parts := splitPath(rel)
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(fp.ToSlash(pattern), "/")
+		anchored := strings.HasPrefix(pattern, "/")
+		pattern = strings.TrimPrefix(pattern, "/")
+		if !anchored && !strings.Contains(pattern, "/") {
+			for _, part := range parts {
+				if ok, _ := fp.Match(pattern, part); ok {
+					return true
+				}
+			}
+			continue
+		}
+
+		for i := 1; i <= len(parts); i++ {
+			if ok, _ := globMatch(pattern, strings.Join(parts[:i], "/")); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// a loaded ignore file, and the directory its patterns are relative to.
+type ignoreFile struct {
+	dir      string
+	patterns []string
+}
+
+// cache of ignore files we've already read, keyed by directory.
+var ignoreFiles = make(map[string]*ignoreFile)
+
+/**
+ * load the patterns from the ignore file in dir (if there is one).
+ */
+func loadIgnoreFile(dir string) *ignoreFile {
+	if ignore, ok := ignoreFiles[dir]; ok {
+		return ignore
+	}
+
+	ignore := &ignoreFile{dir: dir, patterns: make([]string, 0)}
+	ignoreFiles[dir] = ignore
+
+	path := JoinPath(dir, ignoreFileName)
+	fd, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Str("path", path).
+				Str("error", err.Error()).
+				Msg("Failed to open ignore file")
+		}
+		return ignore
+	}
+	defer fd.Close()
+
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ignore.patterns = append(ignore.patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Error().Str("path", path).
+			Str("error", err.Error()).
+			Msg("Failed to read ignore file")
+	}
+	return ignore
+}
+
+/**
+ * Assert whether path is ignored by this ignore file. Paths outside
+ * of the ignore files directory are never ignored.
+ */
+func (ignore *ignoreFile) ignores(path string) bool {
+	if len(ignore.patterns) == 0 {
+		return false
+	}
+	rel, err := fp.Rel(ignore.dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(fp.Separator)) {
+		return false
+	}
+	return globMatchesAny(ignore.patterns, rel)
+}
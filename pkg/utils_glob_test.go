@@ -0,0 +1,136 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGlobMatch_MatchesLikeFilepathMatch(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		{"foo", "foo", true},
+		{"foo/*", "foo/bar", true},
+		{"foo/*", "foo/bar/baz", false},
+		{"*.sh", "foo.sh", true},
+		{"*.sh", "foo.py", false},
+	}
+
+	for _, test := range testCases {
+		if res, _ := globMatch(test.pattern, test.path); res != test.matches {
+			t.Errorf("globMatch(%s, %s): expected != actual, %v != %v",
+				test.pattern, test.path, test.matches, res)
+		}
+	}
+}
+
+func TestGlobMatch_DoubleStarMatchesAnyDepth(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		{"bin/**", "bin/foo", true},
+		{"bin/**", "bin/foo/bar/baz", true},
+		{"bin/**", "lib/foo", false},
+		{"**/*.sh", "foo.sh", true},
+		{"**/*.sh", "foo/bar/baz.sh", true},
+		{"**/*.sh", "foo/bar/baz.py", false},
+		{"foo/**/bar", "foo/bar", true},
+		{"foo/**/bar", "foo/a/b/bar", true},
+		{"foo/**/bar", "foo/a/b/baz", false},
+		{"/foo/**", "/foo/bar", true},
+		{"/foo/**", "foo/bar", false},
+	}
+
+	for _, test := range testCases {
+		if res, _ := globMatch(test.pattern, test.path); res != test.matches {
+			t.Errorf("globMatch(%s, %s): expected != actual, %v != %v",
+				test.pattern, test.path, test.matches, res)
+		}
+	}
+}
+
+func TestGlobBase_StopsAtFirstGlobComponent(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		base    string
+	}{
+		{"foo/bar/*", "foo/bar"},
+		{"foo/**/bar", "foo"},
+		{"/foo/b?r/baz", "/foo"},
+		{"/*", "/"},
+		{"*", ""},
+	}
+
+	for _, test := range testCases {
+		if base := globBase(test.pattern); base != test.base {
+			t.Errorf("globBase(%s): expected != actual, %s != %s", test.pattern, test.base, base)
+		}
+	}
+}
+
+func TestGlobMatchesAny_MatchesComponentsAndParents(t *testing.T) {
+	patterns := []string{"*.swp", "/build", "docs/*.md"}
+	testCases := []struct {
+		path    string
+		matches bool
+	}{
+		{"foo.swp", true},
+		{"bin/.foo.swp", true},
+		{"build", true},
+		{"build/foo", true},
+		{"bin/build", false},
+		{"docs/README.md", true},
+		{"docs/foo/README.md", false},
+		{"bin/foo", false},
+	}
+
+	for _, test := range testCases {
+		if res := globMatchesAny(patterns, test.path); res != test.matches {
+			t.Errorf("globMatchesAny(%s): expected != actual, %v != %v", test.path, test.matches, res)
+		}
+	}
+}
+
+func TestGlob_NeverMatchesIgnoreFiles(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dotty-glob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	os.MkdirAll(fp.Join(tmp, "bin", "sub"), 0755)
+	for _, file := range []string{"foo", ".bar", ignoreFileName, fp.Join("sub", "baz"), fp.Join("sub", ignoreFileName)} {
+		ioutil.WriteFile(fp.Join(tmp, "bin", file), nil, 0644)
+	}
+
+	testCases := []struct {
+		pattern string
+		matches []string
+	}{
+		{"bin/*", []string{"bin/.bar", "bin/foo", "bin/sub"}},
+		{"bin/.*", []string{"bin/.bar"}},
+		{"bin/**", []string{"bin/.bar", "bin/foo", "bin/sub/baz"}},
+		{"bin/**/.dottyignore", []string{}},
+	}
+
+	for _, test := range testCases {
+		matches, err := glob(fp.Join(tmp, test.pattern))
+		if err != nil {
+			t.Fatalf("glob(%s): %s", test.pattern, err)
+		}
+		relMatches := []string{}
+		for _, match := range matches {
+			rel, _ := fp.Rel(tmp, match)
+			relMatches = append(relMatches, fp.ToSlash(rel))
+		}
+		if !reflect.DeepEqual(relMatches, test.matches) {
+			t.Errorf("glob(%s): expected != actual, %v != %v", test.pattern, test.matches, relMatches)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+/**
+ * Find the user and group ids of owner and group, which can each be a name
+ * or a numeric id. An empty owner or group is returned as -1, meaning it
+ * should be left alone.
+ */
+func lookupOwner(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+	if owner != "" {
+		if id, err := strconv.Atoi(owner); err == nil {
+			uid = id
+		} else if u, err := user.Lookup(owner); err == nil {
+			uid, _ = strconv.Atoi(u.Uid)
+		} else {
+			return uid, gid, fmt.Errorf("unknown user %s", owner)
+		}
+	}
+	if group != "" {
+		if id, err := strconv.Atoi(group); err == nil {
+			gid = id
+		} else if g, err := user.LookupGroup(group); err == nil {
+			gid, _ = strconv.Atoi(g.Gid)
+		} else {
+			return uid, gid, fmt.Errorf("unknown group %s", group)
+		}
+	}
+	return uid, gid, nil
+}
+
+// format a user and group id as chown expects them, leaving out either
+// when it's -1.
+func formatOwner(uid, gid int) string {
+	var res string
+	if uid >= 0 {
+		res = strconv.Itoa(uid)
+	}
+	if gid >= 0 {
+		res += ":" + strconv.Itoa(gid)
+	}
+	return res
+}
+
+/**
+ * make sure the file at path (following symlinks) is owned by the user uid
+ * and group gid, changing them if they've drifted. Either id can be -1 to
+ * leave it alone. Returns false if the owner is wrong and couldn't be changed.
+ */
+func reconcileOwner(ops fileOps, path string, uid, gid int) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Error().Str("path", path).
+			Str("error", err.Error()).
+			Msg("Failed to stat path to change owner")
+		return false
+	}
+
+	currentUID, currentGID, ok := fileOwner(info)
+	if ok && (uid < 0 || uid == currentUID) && (gid < 0 || gid == currentGID) {
+		log.Trace().Str("path", path).
+			Str("owner", formatOwner(uid, gid)).
+			Msg("Owner already up to date")
+		return true
+	}
+
+	log.Info().Str("path", path).
+		Str("from", formatOwner(currentUID, currentGID)).
+		Str("to", formatOwner(uid, gid)).
+		Msg("Changing owner")
+	if err := ops.chown(path, uid, gid); err != nil {
+		log.Error().Str("path", path).
+			Str("owner", formatOwner(uid, gid)).
+			Str("error", err.Error()).
+			Msg("Failed to change owner")
+		return false
+	}
+	return true
+}
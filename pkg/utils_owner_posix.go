@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package pkg
+
+import (
+	"os"
+	"syscall"
+)
+
+// the user and group ids that own the file with info.
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1, -1, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/user"
+	fp "path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestLookupOwner(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skip("can't find the current user")
+	}
+	uid, _ := strconv.Atoi(current.Uid)
+
+	if u, g, err := lookupOwner(current.Username, ""); err != nil || u != uid || g != -1 {
+		t.Errorf("expected user %s to be %d with no group, got %d:%d (%v)", current.Username, uid, u, g, err)
+	}
+	if u, g, err := lookupOwner("", "123"); err != nil || u != -1 || g != 123 {
+		t.Errorf("expected numeric group to be used as is, got %d:%d (%v)", u, g, err)
+	}
+	if _, _, err := lookupOwner("dotty-no-such-user", ""); err == nil {
+		t.Error("expected unknown user to fail")
+	}
+}
+
+func TestChmod_ReportsOwnerDrift(t *testing.T) {
+	path := fp.Join(t.TempDir(), "foo")
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+	uid, gid, ok := fileOwner(info)
+	if !ok {
+		t.Skip("file ownership isn't supported")
+	}
+
+	ctx := CreateContext()
+	ctx.chmodOpts["group"] = strconv.Itoa(gid)
+	dir, ok := (&chmodDirective{path: path}).init(ctx)
+	if !ok {
+		t.Fatal("expected chmod with only a group to be valid")
+	}
+	var diff bytes.Buffer
+	if dir.Diff(&diff); diff.Len() != 0 {
+		t.Errorf("expected no drift when the group matches, got %q", diff.String())
+	}
+
+	dir.gid = gid + 1
+	if dir.Diff(&diff); diff.String() != "owner "+formatOwner(uid, gid)+" => "+formatOwner(uid, gid+1)+" "+path+"\n" {
+		t.Errorf("expected group drift to be reported, got %q", diff.String())
+	}
+}
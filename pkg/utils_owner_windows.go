@@ -0,0 +1,8 @@
+package pkg
+
+import "os"
+
+// windows doesn't have user and group ids, so ownership can't be checked.
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	return -1, -1, false
+}
@@ -0,0 +1,192 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/**
+ * A single hunk from a unified diff. lines holds every line in the hunk
+ * with its leading ' ', '-' or '+' marker.
+ */
+type patchHunk struct {
+	oldStart, oldLines int
+	newStart, newLines int
+	lines              []string
+}
+
+var patchHunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+func (hunk patchHunk) header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", hunk.oldStart, hunk.oldLines, hunk.newStart, hunk.newLines)
+}
+
+// split the hunk into the lines it expects to find and what it replaces
+// them with.
+func (hunk patchHunk) split() ([]string, []string) {
+	from := make([]string, 0, hunk.oldLines)
+	to := make([]string, 0, hunk.newLines)
+	for _, line := range hunk.lines {
+		switch line[0] {
+		case ' ':
+			from = append(from, line[1:])
+			to = append(to, line[1:])
+		case '-':
+			from = append(from, line[1:])
+		case '+':
+			to = append(to, line[1:])
+		}
+	}
+	return from, to
+}
+
+// the hunk that undoes this one.
+func (hunk patchHunk) reverse() patchHunk {
+	lines := make([]string, len(hunk.lines))
+	for i, line := range hunk.lines {
+		switch line[0] {
+		case '-':
+			lines[i] = "+" + line[1:]
+		case '+':
+			lines[i] = "-" + line[1:]
+		default:
+			lines[i] = line
+		}
+	}
+	return patchHunk{hunk.newStart, hunk.newLines, hunk.oldStart, hunk.oldLines, lines}
+}
+
+func reversePatch(hunks []patchHunk) []patchHunk {
+	res := make([]patchHunk, len(hunks))
+	for i, hunk := range hunks {
+		res[i] = hunk.reverse()
+	}
+	return res
+}
+
+/**
+ * Parse the hunks from a unified diff of a single file. Any lines outside
+ * of hunks (such as the --- and +++ headers) are ignored, but a diff that
+ * changes more than one file is rejected.
+ */
+func parsePatch(text string) ([]patchHunk, error) {
+	hunks := make([]patchHunk, 0)
+	lines, _ := splitFileLines(text)
+	files := 0
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			if files++; files > 1 {
+				return nil, fmt.Errorf("patch changes more than one file")
+			}
+			i++
+			continue
+		}
+
+		match := patchHunkHeader.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		hunk := patchHunk{oldLines: 1, newLines: 1}
+		hunk.oldStart, _ = strconv.Atoi(match[1])
+		hunk.newStart, _ = strconv.Atoi(match[3])
+		if match[2] != "" {
+			hunk.oldLines, _ = strconv.Atoi(match[2])
+		}
+		if match[4] != "" {
+			hunk.newLines, _ = strconv.Atoi(match[4])
+		}
+
+		from, to := 0, 0
+		for from < hunk.oldLines || to < hunk.newLines {
+			if i++; i >= len(lines) {
+				return nil, fmt.Errorf("hunk %d ends before all its lines were found", len(hunks)+1)
+			}
+			line := lines[i]
+			if line == "" {
+				// some editors strip the trailing space from empty context lines.
+				line = " "
+			}
+			switch line[0] {
+			case ' ':
+				from++
+				to++
+			case '-':
+				from++
+			case '+':
+				to++
+			case '\\':
+				// no newline at end of file.
+				continue
+			default:
+				return nil, fmt.Errorf("line %d: unexpected line in hunk %d", i+1, len(hunks)+1)
+			}
+			hunk.lines = append(hunk.lines, line)
+		}
+		if from != hunk.oldLines || to != hunk.newLines {
+			return nil, fmt.Errorf("hunk %d has more lines than its header says", len(hunks)+1)
+		}
+		hunks = append(hunks, hunk)
+	}
+
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("patch doesn't contain any hunks")
+	}
+	return hunks, nil
+}
+
+/**
+ * Apply hunks to lines. Each hunk is first tried where its header says it
+ * should be and then at the nearest position its lines match exactly,
+ * returning an error for the first hunk that can't be found.
+ */
+func applyPatch(lines []string, hunks []patchHunk) ([]string, error) {
+	res := make([]string, 0, len(lines))
+	pos, offset := 0, 0
+	for i, hunk := range hunks {
+		from, to := hunk.split()
+		// the start of an empty hunk is the line before it.
+		want := hunk.oldStart - 1
+		if hunk.oldLines == 0 {
+			want = hunk.oldStart
+		}
+
+		at := findPatchLines(lines, from, want+offset, pos)
+		if at == -1 {
+			return nil, fmt.Errorf("hunk %d (%s) doesn't apply", i+1, hunk.header())
+		}
+		res = append(res, lines[pos:at]...)
+		res = append(res, to...)
+		pos, offset = at+len(from), at-want
+	}
+	return append(res, lines[pos:]...), nil
+}
+
+// find the position in lines, no earlier than min, closest to want where
+// every line in from appears.
+func findPatchLines(lines, from []string, want, min int) int {
+	max := len(lines) - len(from)
+	matches := func(at int) bool {
+		if at < min || at > max {
+			return false
+		}
+		for i, line := range from {
+			if lines[at+i] != line {
+				return false
+			}
+		}
+		return true
+	}
+
+	for delta := 0; want-delta >= min || want+delta <= max; delta++ {
+		if matches(want - delta) {
+			return want - delta
+		}
+		if matches(want + delta) {
+			return want + delta
+		}
+	}
+	return -1
+}
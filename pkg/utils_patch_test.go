@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPatch = `--- a/foo.conf
++++ b/foo.conf
+@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+@@ -6,2 +6,3 @@
+ f
+ g
++h
+`
+
+func testPatchLines(text string) []string {
+	lines, _ := splitFileLines(text)
+	return lines
+}
+
+func TestApplyPatch_AppliesHunks(t *testing.T) {
+	hunks, err := parsePatch(testPatch)
+	if err != nil {
+		t.Fatalf("failed to parse patch: %s", err)
+	}
+
+	res, err := applyPatch(testPatchLines("a\nb\nc\nd\ne\nf\ng\n"), hunks)
+	if err != nil {
+		t.Fatalf("failed to apply patch: %s", err)
+	}
+	if joined := strings.Join(res, "\n"); joined != "a\nB\nc\nd\ne\nf\ng\nh" {
+		t.Errorf("unexpected result %q", joined)
+	}
+}
+
+func TestApplyPatch_FindsHunksAtAnOffset(t *testing.T) {
+	hunks, _ := parsePatch(testPatch)
+	res, err := applyPatch(testPatchLines("x\ny\na\nb\nc\nd\ne\nf\ng\n"), hunks)
+	if err != nil {
+		t.Fatalf("failed to apply patch: %s", err)
+	}
+	if joined := strings.Join(res, "\n"); joined != "x\ny\na\nB\nc\nd\ne\nf\ng\nh" {
+		t.Errorf("unexpected result %q", joined)
+	}
+}
+
+func TestApplyPatch_ReversesAppliedPatch(t *testing.T) {
+	hunks, _ := parsePatch(testPatch)
+	applied := testPatchLines("a\nB\nc\nd\ne\nf\ng\nh\n")
+	if _, err := applyPatch(applied, hunks); err == nil {
+		t.Error("patch applied twice")
+	}
+
+	res, err := applyPatch(applied, reversePatch(hunks))
+	if err != nil {
+		t.Fatalf("failed to reverse patch: %s", err)
+	}
+	if joined := strings.Join(res, "\n"); joined != "a\nb\nc\nd\ne\nf\ng" {
+		t.Errorf("unexpected result %q", joined)
+	}
+}
+
+func TestApplyPatch_RejectsMismatchedHunk(t *testing.T) {
+	hunks, _ := parsePatch(testPatch)
+	_, err := applyPatch(testPatchLines("a\nb\nc\nd\ne\nf\nz\n"), hunks)
+	if err == nil || !strings.Contains(err.Error(), "hunk 2") {
+		t.Errorf("expected second hunk to be rejected, got %v", err)
+	}
+}
+
+func TestParsePatch_RejectsMultipleFiles(t *testing.T) {
+	if _, err := parsePatch(testPatch + "--- a/bar\n+++ b/bar\n@@ -1 +1 @@\n-a\n+b\n"); err == nil {
+		t.Error("expected patch changing two files to be rejected")
+	}
+}
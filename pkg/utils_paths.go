@@ -46,14 +46,14 @@ func recursiveBuildPath(
 	ch chan string,
 	paths AnySlice,
 	base string,
-	preJoin func(string) (string, bool),
+	preJoin func(string) ([]string, bool),
 	err recursiveBuildPathErrorCallback,
 ) {
 	defer close(ch)
 	var recursiveDo func(paths AnySlice, base string)
 	recursiveDo = func(paths AnySlice, base string) {
 		lastRecurse := 0
-		i, currentPaths := 0, make([]string, len(paths))
+		currentPaths := make([]string, 0, len(paths))
 
 		for _, path := range paths {
 			if path == nil {
@@ -61,30 +61,31 @@ func recursiveBuildPath(
 			}
 
 			if pathStr, ok := path.(string); ok {
-				if pathStr, ok := preJoin(pathStr); ok {
-					currentPaths[i] = JoinPath(base, fp.FromSlash(pathStr))
-					i++
+				if pathStrs, ok := preJoin(pathStr); ok {
+					for _, pathStr := range pathStrs {
+						currentPaths = append(currentPaths, JoinPath(base, fp.FromSlash(pathStr)))
+					}
 				}
 			} else if pathSlice, ok := path.(AnySlice); ok {
-				for j, dir := range currentPaths {
-					if j < i {
-						recursiveDo(pathSlice, dir)
-					}
+				for _, dir := range currentPaths {
+					recursiveDo(pathSlice, dir)
 				}
-				lastRecurse = i
+				lastRecurse = len(currentPaths)
 			} else {
 				err(base, path)
 			}
 		}
-		for j := lastRecurse; j < i; j++ {
-			ch <- currentPaths[j]
+		for _, path := range currentPaths[lastRecurse:] {
+			ch <- path
 		}
 	}
 
 	for _, path := range paths {
 		if pathStr, ok := path.(string); ok {
-			if pathStr, ok := preJoin(pathStr); ok {
-				ch <- JoinPath(base, fp.FromSlash(pathStr))
+			if pathStrs, ok := preJoin(pathStr); ok {
+				for _, pathStr := range pathStrs {
+					ch <- JoinPath(base, fp.FromSlash(pathStr))
+				}
 			}
 		} else if pathSlice, ok := path.(AnySlice); ok {
 			recursiveDo(pathSlice, base)
@@ -94,8 +95,8 @@ func recursiveBuildPath(
 	}
 }
 
-func recursiveBuildPathIdentityPreJoin(a string) (string, bool) {
-	return a, true
+func recursiveBuildPathIdentityPreJoin(a string) ([]string, bool) {
+	return []string{a}, true
 }
 
 /**
@@ -127,7 +128,7 @@ func recursiveBuildDirectivesFromPaths(
 		done <- struct{}{}
 	}()
 
-	go recursiveBuildPath(pathCh, args, ctx.Cwd, ctx.eval, func(base string, arg Any) {
+	go recursiveBuildPath(pathCh, args, ctx.Cwd, ctx.evalPaths, func(base string, arg Any) {
 		// the only situation in which the input can not be a path is when
 		// it's a map containing perhaps more directories.
 		sMap, ok := arg.(map[Any]Any)
@@ -215,7 +216,7 @@ func ExpandTilde(homeDir, path string) string {
  */
 func fileIsRelative(basepath, targPath string) bool {
 	if res, err := fp.Rel(targPath, basepath); err == nil {
-		return !strings.HasPrefix(res, ".."+string(fp.Separator))
+		return res != ".." && !strings.HasPrefix(res, ".."+string(fp.Separator))
 	}
 
 	// can't be made relative so they aren't relative to each other.
@@ -0,0 +1,38 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// shared reader for user responses, so buffered input isn't lost
+// between prompts.
+var promptReader = bufio.NewReader(os.Stdin)
+
+/**
+ * ask the user question and return their (trimmed) response.
+ * if reading from stdin fails the response is empty.
+ */
+func promptUser(question string) string {
+	fmt.Fprintf(os.Stderr, "%s ", question)
+	line, err := promptReader.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(os.Stderr)
+		return ""
+	}
+	return strings.TrimSpace(line)
+}
+
+/**
+ * ask the user a yes or no question, defaulting to no.
+ */
+func promptConfirm(question string) bool {
+	switch strings.ToLower(promptUser(question + "? [y/N]")) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,96 @@
+package pkg
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/**
+ * Proxy settings for HTTP requests, following the same conventions as curl
+ * and net/http. httpsProxy and httpProxy are the proxies for requests with
+ * each scheme and noProxy is a comma separated list of hosts, domains (which
+ * match their subdomains as well), IP addresses or CIDR ranges that should
+ * be connected to directly.
+ */
+type proxyConfig struct {
+	httpsProxy string
+	httpProxy  string
+	noProxy    string
+}
+
+// read proxy settings from the environment given by lookup. the upper case
+// variables take precedence over the lower case ones.
+func proxyConfigFromEnv(lookup func(string) (string, bool)) proxyConfig {
+	get := func(name string) string {
+		if val, ok := lookup(strings.ToUpper(name)); ok && val != "" {
+			return val
+		}
+		val, _ := lookup(name)
+		return val
+	}
+	return proxyConfig{get("https_proxy"), get("http_proxy"), get("no_proxy")}
+}
+
+// the proxy req should be sent through, or nil to connect directly. this is
+// meant to be used as the Proxy of an http.Transport.
+func (cfg proxyConfig) proxy(req *http.Request) (*url.URL, error) {
+	proxy := cfg.httpProxy
+	if req.URL.Scheme == "https" {
+		proxy = cfg.httpsProxy
+	}
+	if proxy == "" || cfg.bypass(req.URL.Hostname()) {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Host == "" {
+		// proxies are often given without a scheme, such as proxy:8080.
+		if proxyURL, err = url.Parse("http://" + proxy); err != nil {
+			return nil, fmt.Errorf("invalid proxy address %s", proxy)
+		}
+	}
+	return proxyURL, nil
+}
+
+// assert whether requests to host should skip the proxy. requests to the
+// local machine are never proxied.
+func (cfg proxyConfig) bypass(host string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	if host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return true
+	}
+
+	for _, entry := range strings.Split(cfg.noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "*" {
+			return true
+		} else if entry == "" {
+			continue
+		}
+
+		if ip != nil {
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && cidr.Contains(ip) {
+				return true
+			}
+		}
+		// ports are ignored, an entry matches every port on its host.
+		if entryHost, _, err := net.SplitHostPort(entry); err == nil {
+			entry = entryHost
+		}
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
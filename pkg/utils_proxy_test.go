@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	fp "path/filepath"
+	"testing"
+)
+
+func TestProxyConfigFromEnv_PrefersUpperCase(t *testing.T) {
+	env := map[string]string{
+		"HTTPS_PROXY": "http://upper:8080",
+		"https_proxy": "http://lower:8080",
+		"http_proxy":  "http://lower:3128",
+		"NO_PROXY":    "",
+		"no_proxy":    "example.com",
+	}
+	cfg := proxyConfigFromEnv(func(name string) (string, bool) {
+		val, ok := env[name]
+		return val, ok
+	})
+	expected := proxyConfig{"http://upper:8080", "http://lower:3128", "example.com"}
+	if cfg != expected {
+		t.Errorf("expected proxy config %v, got %v", expected, cfg)
+	}
+}
+
+func TestProxyConfig_Proxy(t *testing.T) {
+	cfg := proxyConfig{
+		httpsProxy: "proxy.example.com:8080",
+		httpProxy:  "http://proxy.example.com:3128",
+		noProxy:    "internal.example.com, .corp, 10.0.0.0/8,192.168.1.1,*.local:443",
+	}
+	for target, proxy := range map[string]string{
+		"https://github.com/foo":           "http://proxy.example.com:8080",
+		"http://github.com/foo":            "http://proxy.example.com:3128",
+		"https://internal.example.com/foo": "",
+		"https://git.internal.example.com": "",
+		"https://notinternal.example.com":  "http://proxy.example.com:8080",
+		"https://foo.corp/bar":             "",
+		"https://10.1.2.3/foo":             "",
+		"https://192.168.1.1:8443/foo":     "",
+		"https://192.168.1.2/foo":          "http://proxy.example.com:8080",
+		"https://printer.local/foo":        "",
+		"http://localhost:8000/foo":        "",
+		"http://127.0.0.1/foo":             "",
+	} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		res, err := cfg.proxy(req)
+		if err != nil {
+			t.Errorf("failed to find proxy for %s: %s", target, err)
+		} else if (res == nil && proxy != "") || (res != nil && res.String() != proxy) {
+			t.Errorf("expected proxy for %s to be %q, got %v", target, proxy, res)
+		}
+	}
+}
+
+func TestDownloadClient_UsesProxyFromContext(t *testing.T) {
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		w.Write(testDownloadContent)
+	}))
+	defer proxy.Close()
+
+	ctx := CreateContext()
+	ctx.envOpts["HTTP_PROXY"] = proxy.URL
+	ctx.envOpts["NO_PROXY"] = ""
+	path := fp.Join(t.TempDir(), "tool")
+	if err := downloadFile(downloadClient(ctx), "http://downloads.example.com/tool", path, 0); err != nil {
+		t.Fatalf("failed to download through proxy: %s", err)
+	}
+	if requested != "http://downloads.example.com/tool" {
+		t.Errorf("expected proxy to receive request for the download, got %q", requested)
+	}
+	assertDownloaded(t, path)
+}
@@ -0,0 +1,231 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/term"
+)
+
+// what secrets are replaced with in logs and other output.
+const redactedSecret = "[REDACTED]"
+
+// the values of every secret decrypted in this run, which must never be output.
+var secretValues = struct {
+	sync.RWMutex
+	values   map[string]struct{}
+	replacer *strings.Replacer
+}{values: make(map[string]struct{})}
+
+// lines of multi-line secrets shorter than this aren't redacted on their own,
+// so common lines (like a closing brace) don't get redacted everywhere.
+const secretLineMinLength = 8
+
+/**
+ * Record val as a secret, so it's redacted from any output passed through
+ * RedactSecrets. The form val would take when quoted in a log message, and
+ * any long enough lines of it, are also redacted.
+ */
+func registerSecret(val string) {
+	forms := []string{val}
+	if quoted := strconv.Quote(val); quoted[1:len(quoted)-1] != val {
+		forms = append(forms, quoted[1:len(quoted)-1])
+	}
+	if strings.Contains(val, "\n") {
+		for _, line := range strings.Split(val, "\n") {
+			if len(strings.TrimSpace(line)) >= secretLineMinLength {
+				forms = append(forms, line)
+			}
+		}
+	}
+
+	secretValues.Lock()
+	defer secretValues.Unlock()
+	for _, form := range forms {
+		if form = strings.TrimSpace(form); form != "" {
+			secretValues.values[form] = struct{}{}
+		}
+	}
+
+	// replace longer secrets first, so one secret containing another is
+	// redacted entirely.
+	values := make([]string, 0, len(secretValues.values))
+	for value := range secretValues.values {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	pairs := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		pairs = append(pairs, value, redactedSecret)
+	}
+	secretValues.replacer = strings.NewReplacer(pairs...)
+}
+
+// replace every secret in str with a placeholder.
+func RedactSecrets(str string) string {
+	secretValues.RLock()
+	defer secretValues.RUnlock()
+	if secretValues.replacer == nil {
+		return str
+	}
+	return secretValues.replacer.Replace(str)
+}
+
+// assert whether str contains any secrets.
+func containsSecret(str string) bool {
+	return RedactSecrets(str) != str
+}
+
+type redactingWriter struct {
+	w io.Writer
+}
+
+/**
+ * Wrap w so any secrets written to it are redacted. Secrets are only found
+ * when they're written in a single call, which is how zerolog writes each
+ * event.
+ */
+func NewRedactingWriter(w io.Writer) io.Writer {
+	return redactingWriter{w}
+}
+
+func (w redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, RedactSecrets(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// asks for the passphrase of passphrase encrypted secrets, overridable for tests.
+var secretPassphrasePrompt = func() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("a passphrase is required to decrypt secrets, but stdin isn't a terminal")
+	}
+	fmt.Fprint(os.Stderr, "Enter passphrase for secrets: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(passphrase), err
+}
+
+// the passphrase given for secrets, so we only ask for it once per run.
+var secretPassphrase = struct {
+	sync.Mutex
+	value string
+	set   bool
+}{}
+
+// an age identity for passphrase encrypted files, that only asks for the
+// passphrase when it's given such a file.
+type lazyScryptIdentity struct{}
+
+func (lazyScryptIdentity) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	isScrypt := false
+	for _, stanza := range stanzas {
+		isScrypt = isScrypt || stanza.Type == "scrypt"
+	}
+	if !isScrypt {
+		return nil, age.ErrIncorrectIdentity
+	}
+
+	secretPassphrase.Lock()
+	defer secretPassphrase.Unlock()
+	if !secretPassphrase.set {
+		passphrase, err := secretPassphrasePrompt()
+		if err != nil {
+			return nil, err
+		}
+		secretPassphrase.value, secretPassphrase.set = passphrase, true
+	}
+	identity, err := age.NewScryptIdentity(secretPassphrase.value)
+	if err != nil {
+		return nil, err
+	}
+	key, err := identity.Unwrap(stanzas)
+	if err != nil {
+		// let the user try again for the next secret.
+		secretPassphrase.set = false
+	}
+	return key, err
+}
+
+// resolve a path to a secret, or an identity, from the config.
+func (ctx *Context) secretPath(path string) (string, bool) {
+	path, ok := ctx.eval(path)
+	if !ok {
+		return "", false
+	}
+	return ExpandTilde(ctx.Home, JoinPath(ctx.Cwd, fp.FromSlash(path))), true
+}
+
+// the identities secrets can be decrypted with, the private keys in the files
+// given by the :identity option followed by a passphrase.
+func (ctx *Context) secretIdentities() ([]age.Identity, error) {
+	var paths []string
+	if !readMapOptionStrings(ctx.secretOpts, nil, &paths, "identity", nil) {
+		return nil, errors.New("invalid identity option")
+	}
+
+	var identities []age.Identity
+	for _, path := range paths {
+		path, ok := ctx.secretPath(path)
+		if !ok {
+			return nil, errors.New("invalid identity path")
+		}
+		fd, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := age.ParseIdentities(fd)
+		fd.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read identities from %s: %s", path, err)
+		}
+		identities = append(identities, ids...)
+	}
+	return append(identities, lazyScryptIdentity{}), nil
+}
+
+/**
+ * Decrypt the age encrypted file at path, which may be armored, in memory.
+ * The decrypted contents are registered as a secret, so they're never logged.
+ */
+func (ctx *Context) decryptSecret(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var src io.Reader = bytes.NewReader(data)
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); bytes.HasPrefix(trimmed, []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(trimmed))
+	}
+
+	identities, err := ctx.secretIdentities()
+	if err != nil {
+		return "", err
+	}
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return "", err
+	}
+	secret, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	registerSecret(string(secret))
+	log.Debug().Str("path", path).
+		Msg("Decrypted secret")
+	return string(secret), nil
+}
@@ -0,0 +1,200 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// encrypt secret to dest, armored when armored is true.
+func testEncryptSecret(t *testing.T, dest, secret string, armored bool, recipients ...age.Recipient) {
+	var buf bytes.Buffer
+	var out io.WriteCloser
+	var w io.WriteCloser
+	var err error
+	if armored {
+		out = armor.NewWriter(&buf)
+		w, err = age.Encrypt(out, recipients...)
+	} else {
+		w, err = age.Encrypt(&buf, recipients...)
+	}
+	if err != nil {
+		t.Fatalf("failed to encrypt secret: %s", err)
+	}
+	if _, err = io.WriteString(w, secret); err == nil {
+		err = w.Close()
+	}
+	if err == nil && out != nil {
+		err = out.Close()
+	}
+	if err == nil {
+		err = ioutil.WriteFile(dest, buf.Bytes(), 0644)
+	}
+	if err != nil {
+		t.Fatalf("failed to write secret: %s", err)
+	}
+}
+
+// a context in a temporary directory, with an identity file at identity.txt.
+func testSecretContext(t *testing.T) (*Context, *age.X25519Identity) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %s", err)
+	}
+	ctx := CreateContext()
+	ctx.Cwd = t.TempDir()
+	ctx.Home = ctx.Cwd
+	if err = ioutil.WriteFile(fp.Join(ctx.Cwd, "identity.txt"), []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write identity: %s", err)
+	}
+	ctx.secretOpts["identity"] = []Any{"~/identity.txt"}
+	return ctx, identity
+}
+
+func TestDef_Secret(t *testing.T) {
+	ctx, identity := testSecretContext(t)
+	testEncryptSecret(t, fp.Join(ctx.Cwd, "token.age"), "hunter2-secret-token\n", false, identity.Recipient())
+	testEncryptSecret(t, fp.Join(ctx.Cwd, "armored.age"), "an-armored-secret", true, identity.Recipient())
+
+	dDef(ctx, pathsFromEdn(`("TOKEN" (:secret "token.age")
+	                         "ARMORED" (:secret "armored.age"))`))
+	if val := ctx.envOpts["TOKEN"]; val != "hunter2-secret-token" {
+		t.Errorf("expected TOKEN to be decrypted without its trailing newline, got %q", val)
+	}
+	if val := ctx.envOpts["ARMORED"]; val != "an-armored-secret" {
+		t.Errorf("expected ARMORED to be decrypted, got %q", val)
+	}
+
+	msg := fmt.Sprintf("token=%q armored=an-armored-secret", "hunter2-secret-token\n")
+	if redacted := RedactSecrets(msg); strings.Contains(redacted, "secret-token") || strings.Contains(redacted, "an-armored") {
+		t.Errorf("expected secrets to be redacted, got %q", redacted)
+	}
+}
+
+func TestDecryptSecret_Passphrase(t *testing.T) {
+	ctx, _ := testSecretContext(t)
+	recipient, err := age.NewScryptRecipient("correct horse")
+	if err != nil {
+		t.Fatalf("failed to create recipient: %s", err)
+	}
+	recipient.SetWorkFactor(10)
+	path := fp.Join(ctx.Cwd, "secret.age")
+	testEncryptSecret(t, path, "passphrase-protected", false, recipient)
+
+	prompts := 0
+	passphrases := []string{"wrong", "correct horse"}
+	defer func(prompt func() (string, error)) {
+		secretPassphrasePrompt = prompt
+		secretPassphrase.set = false
+	}(secretPassphrasePrompt)
+	secretPassphrasePrompt = func() (string, error) {
+		if prompts >= len(passphrases) {
+			return "", errors.New("no passphrase left")
+		}
+		prompts++
+		return passphrases[prompts-1], nil
+	}
+
+	if _, err = ctx.decryptSecret(path); err == nil {
+		t.Error("expected decrypting with the wrong passphrase to fail")
+	}
+	for i := 0; i < 2; i++ {
+		if secret, err := ctx.decryptSecret(path); err != nil || secret != "passphrase-protected" {
+			t.Errorf("expected secret to be decrypted, got %q (%v)", secret, err)
+		}
+	}
+	if prompts != 2 {
+		t.Errorf("expected to only prompt again after a wrong passphrase, prompted %d times", prompts)
+	}
+}
+
+func TestFile_SecretIsRestrictedAndRedacted(t *testing.T) {
+	ctx, identity := testSecretContext(t)
+	testEncryptSecret(t, fp.Join(ctx.Cwd, "config.age"), "password = file-secret-value\n", false, identity.Recipient())
+
+	dest := fp.Join(ctx.Cwd, "config")
+	dir, ok := (&fileDirective{dest: dest}).init(ctx, pathsFromEdn(`({:secret "config.age"})`)[0].(map[Any]Any))
+	if !ok {
+		t.Fatal("expected file directive with a secret to be created")
+	}
+	if dir.content != "password = file-secret-value\n" {
+		t.Errorf("expected content to be decrypted, got %q", dir.content)
+	}
+	if !dir.modeSet || dir.mode != secretFileMode {
+		t.Errorf("expected secret files to default to mode %s, got %s", formatMode(secretFileMode), formatMode(dir.mode))
+	}
+	if strings.Contains(dir.Log(), "file-secret-value") {
+		t.Errorf("expected secret to be hidden from the log, got %q", dir.Log())
+	}
+
+	var diff bytes.Buffer
+	dir.Diff(&diff)
+	if strings.Contains(diff.String(), "file-secret-value") || !strings.Contains(diff.String(), dest) {
+		t.Errorf("expected diff to only mention that dest differs, got %q", diff.String())
+	}
+
+	dir.Run()
+	if info, err := os.Stat(dest); err != nil || info.Mode().Perm() != secretFileMode {
+		t.Errorf("expected dest to be written with mode %s, got %v (%v)", formatMode(secretFileMode), info, err)
+	}
+}
+
+func TestFile_SecretRestrictsExistingDestBeforeWriting(t *testing.T) {
+	if isWindows() {
+		t.Skip("sudo isn't supported on windows")
+	}
+	ctx, identity := testSecretContext(t)
+	testEncryptSecret(t, fp.Join(ctx.Cwd, "config.age"), "password = file-secret-value\n", false, identity.Recipient())
+
+	// record the permissions of dest whenever it's copied over.
+	modes := fp.Join(ctx.Cwd, "modes")
+	defer testFakeSudo(t, `[ "$1" = cp ] && eval "ls -l \"\${$#}\"" | cut -c1-10 >>`+modes)()
+
+	dest := fp.Join(ctx.Cwd, "config")
+	if err := ioutil.WriteFile(dest, []byte("password = old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir, ok := (&fileDirective{dest: dest}).init(ctx, pathsFromEdn(`({:secret "config.age" :sudo true})`)[0].(map[Any]Any))
+	if !ok {
+		t.Fatal("expected file directive with a secret to be created")
+	}
+	dir.Run()
+	if written, err := ioutil.ReadFile(modes); err != nil || string(written) != "-rw-------\n" {
+		t.Errorf("expected dest to be restricted before the secret was written, got %q (%v)", written, err)
+	}
+	if info, err := os.Stat(dest); err != nil || info.Mode().Perm() != secretFileMode {
+		t.Errorf("expected dest to have mode %s, got %v (%v)", formatMode(secretFileMode), info, err)
+	}
+}
+
+func TestFile_ContentWithSecretIsRestricted(t *testing.T) {
+	ctx := CreateContext()
+	registerSecret("some-interpolated-secret")
+	ctx.envOpts["TOKEN"] = "some-interpolated-secret"
+
+	dir, ok := (&fileDirective{dest: "/tmp/foo"}).init(ctx, pathsFromEdn(`({:content "token=${TOKEN}"})`)[0].(map[Any]Any))
+	if !ok {
+		t.Fatal("expected file directive to be created")
+	}
+	if !dir.secret || dir.mode != secretFileMode {
+		t.Errorf("expected content containing a secret to be restricted, got mode %s", formatMode(dir.mode))
+	}
+}
+
+func TestRedactingWriter(t *testing.T) {
+	registerSecret("first line of secret\n}\nsecond line of secret")
+	var buf bytes.Buffer
+	fmt.Fprint(NewRedactingWriter(&buf), "a first line of secret and }")
+	if expected := "a " + redactedSecret + " and }"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,135 @@
+package pkg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+/**
+ * File system operations for directives, which can optionally be performed
+ * as root. When sudo is true each operation runs the equivalent coreutils
+ * command through sudo and logs exactly what was run, otherwise the operation
+ * is performed directly by dotty as the current user.
+ */
+type fileOps struct {
+	sudo bool
+}
+
+// whether sudo has already been validated during this run.
+var sudoValidated bool
+
+// run cmd as root, attaching it to dottys standard streams so sudo can
+// ask for a password.
+func (ops fileOps) run(cmd ...string) error {
+	if isWindows() {
+		return fmt.Errorf("elevated file operations aren't supported on windows")
+	}
+	if !sudoValidated {
+		if !sudoValidate() {
+			return fmt.Errorf("failed to elevate user privileges")
+		}
+		sudoValidated = true
+	}
+
+	cmdLine := append([]string{"sudo", "--"}, cmd...)
+	log.Info().Strs("cmd", cmdLine).
+		Msg("Running as root")
+	return buildCommand(cmdLine, "", nil, true, true, true).Run()
+}
+
+func (ops fileOps) symlink(src, dest string) error {
+	if ops.sudo {
+		return ops.run("ln", "-s", "--", src, dest)
+	}
+	return os.Symlink(src, dest)
+}
+
+func (ops fileOps) link(src, dest string) error {
+	if ops.sudo {
+		return ops.run("ln", "--", src, dest)
+	}
+	return os.Link(src, dest)
+}
+
+func (ops fileOps) remove(path string) error {
+	if ops.sudo {
+		return ops.run("rm", "-f", "--", path)
+	}
+	return os.Remove(path)
+}
+
+func (ops fileOps) mkdirAll(path string, mode os.FileMode) error {
+	if !ops.sudo {
+		return os.MkdirAll(path, mode)
+	}
+
+	// mkdir -p only gives the last directory mode, so we list every missing
+	// directory from the outermost in instead.
+	var missing []string
+	for dir := fp.Clean(path); ; dir = fp.Dir(dir) {
+		if _, err := os.Lstat(dir); !os.IsNotExist(err) {
+			break
+		}
+		missing = append([]string{dir}, missing...)
+		if fp.Dir(dir) == dir {
+			break
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return ops.run(append([]string{"mkdir", "-m", formatMode(mode), "--"}, missing...)...)
+}
+
+func (ops fileOps) chmod(path string, mode os.FileMode) error {
+	if ops.sudo {
+		return ops.run("chmod", formatMode(mode), "--", path)
+	}
+	return os.Chmod(path, mode)
+}
+
+// change the owner of path to the user uid and group gid, either of which
+// can be -1 to leave it alone.
+func (ops fileOps) chown(path string, uid, gid int) error {
+	if ops.sudo {
+		return ops.run("chown", formatOwner(uid, gid), "--", path)
+	}
+	return os.Chown(path, uid, gid)
+}
+
+func (ops fileOps) rename(src, dest string) error {
+	if ops.sudo {
+		return ops.run("mv", "--", src, dest)
+	}
+	return moveFile(src, dest)
+}
+
+// write data to path, keeping the permissions and owner of path when it
+// already exists. As root the data is written to a temporary file first
+// and then copied over path.
+func (ops fileOps) writeFile(path string, data []byte, mode os.FileMode) error {
+	if !ops.sudo {
+		return ioutil.WriteFile(path, data, mode)
+	}
+
+	tmp, err := ioutil.TempFile("", "dotty-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return ops.run("cp", "--", tmp.Name(), path)
+}
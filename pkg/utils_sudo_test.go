@@ -0,0 +1,41 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"strings"
+	"testing"
+)
+
+// put a sudo on the PATH that runs commands as the current user, after
+// running any shell hooks with the command as their arguments.
+func testFakeSudo(t *testing.T, hooks ...string) func() {
+	bin := t.TempDir()
+	script := "#!/bin/sh\n[ \"$1\" = --validate ] && exit 0\nshift\n" +
+		strings.Join(append(hooks, "exec \"$@\"\n"), "\n")
+	if err := ioutil.WriteFile(fp.Join(bin, "sudo"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", bin+string(os.PathListSeparator)+path)
+	return func() { os.Setenv("PATH", path) }
+}
+
+func TestFileOps_SudoMkdirAllAppliesModeToEveryDirectory(t *testing.T) {
+	if isWindows() {
+		t.Skip("sudo isn't supported on windows")
+	}
+	defer testFakeSudo(t)()
+
+	root := t.TempDir()
+	path := fp.Join(root, "foo", "bar")
+	if err := (fileOps{true}).mkdirAll(path, 0700); err != nil {
+		t.Fatalf("failed to make directories: %s", err)
+	}
+	for _, dir := range []string{fp.Join(root, "foo"), path} {
+		if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0700 {
+			t.Errorf("expected %s to be created with mode 0700, got %v (%v)", dir, info, err)
+		}
+	}
+}
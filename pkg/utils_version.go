@@ -0,0 +1,169 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/**
+ * A dotted version number, such as 2.30.1. Any pre-release or build suffix
+ * is dropped, so versions are only compared by their numeric components.
+ */
+type version []int
+
+var versionRegexp = regexp.MustCompile(`\d+(?:\.\d+)*`)
+
+// find the first version number in text, such as the output of git --version.
+// dotted versions are preferred over lone numbers, which are often part of a
+// program name (like python3).
+func findVersion(text string) (version, bool) {
+	matches := versionRegexp.FindAllString(text, -1)
+	for _, match := range matches {
+		if strings.Contains(match, ".") {
+			return parseVersion(match)
+		}
+	}
+	if len(matches) > 0 {
+		return parseVersion(matches[0])
+	}
+	return nil, false
+}
+
+func parseVersion(str string) (version, bool) {
+	str = strings.TrimPrefix(strings.TrimSpace(str), "v")
+	if str == "" {
+		return nil, false
+	}
+	parts := strings.Split(str, ".")
+	res := make(version, len(parts))
+	for i, part := range parts {
+		num, err := strconv.Atoi(part)
+		if err != nil || num < 0 {
+			return nil, false
+		}
+		res[i] = num
+	}
+	return res, true
+}
+
+func (v version) String() string {
+	parts := make([]string, len(v))
+	for i, num := range v {
+		parts[i] = strconv.Itoa(num)
+	}
+	return strings.Join(parts, ".")
+}
+
+// compare the first n components of v and other, treating missing components
+// as 0. returns -1, 0 or 1 when v is less than, equal to or greater than other.
+func (v version) compare(other version, n int) int {
+	for i := 0; i < n; i++ {
+		var a, b int
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(other) {
+			b = other[i]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// a single comparison from a version constraint, such as >= 2.30.
+type versionConstraint struct {
+	op      string
+	version version
+}
+
+// operators in the order they should be matched, so >= isn't read as >.
+var versionOperators = []string{">=", "<=", "!=", "==", ">", "<", "=", "~", "^"}
+
+/**
+ * Parse a version constraint, a comma separated list of comparisons which
+ * must all hold, such as ">= 2.30, < 3". The supported operators are:
+ *   =, ==, != compare only the components given, so = 2.30 matches 2.30.1.
+ *   >, >=, <, <= treat missing components as 0.
+ *   ~1.2.3 allows patch level changes, >= 1.2.3, < 1.3. ~1 allows any 1.x.
+ *   ^1.2.3 allows changes that don't modify the leftmost non-zero component,
+ *          >= 1.2.3, < 2.
+ * A comparison without an operator is treated as =.
+ */
+func parseVersionConstraints(str string) ([]versionConstraint, error) {
+	var res []versionConstraint
+	for _, part := range strings.Split(str, ",") {
+		part = strings.TrimSpace(part)
+		op := "="
+		for _, candidate := range versionOperators {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				part = part[len(candidate):]
+				break
+			}
+		}
+		v, ok := parseVersion(part)
+		if !ok {
+			return nil, fmt.Errorf("invalid version in constraint: %q", str)
+		}
+		if op == "==" {
+			op = "="
+		}
+		res = append(res, versionConstraint{op, v})
+	}
+	return res, nil
+}
+
+func (c versionConstraint) matches(v version) bool {
+	n := maxInt(len(v), len(c.version))
+	switch c.op {
+	case "=":
+		return v.compare(c.version, len(c.version)) == 0
+	case "!=":
+		return v.compare(c.version, len(c.version)) != 0
+	case ">":
+		return v.compare(c.version, n) > 0
+	case ">=":
+		return v.compare(c.version, n) >= 0
+	case "<":
+		return v.compare(c.version, n) < 0
+	case "<=":
+		return v.compare(c.version, n) <= 0
+	case "~":
+		prefix := len(c.version)
+		if prefix > 2 {
+			prefix = 2
+		}
+		return v.compare(c.version, n) >= 0 && v.compare(c.version, prefix) == 0
+	case "^":
+		prefix := 1
+		for prefix < len(c.version) && c.version[prefix-1] == 0 {
+			prefix++
+		}
+		return v.compare(c.version, n) >= 0 && v.compare(c.version, prefix) == 0
+	}
+	return false
+}
+
+// assert whether v satisfies every one of constraints.
+func versionMatches(v version, constraints []versionConstraint) bool {
+	for _, c := range constraints {
+		if !c.matches(v) {
+			return false
+		}
+	}
+	return true
+}
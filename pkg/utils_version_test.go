@@ -0,0 +1,69 @@
+package pkg
+
+import "testing"
+
+func TestFindVersion_ExtractsVersionFromOutput(t *testing.T) {
+	testCases := map[string]string{
+		"git version 2.34.1\n":                 "2.34.1",
+		"NVIM v0.9.5\nBuild type: Release":     "0.9.5",
+		"Python 3.10.12":                       "3.10.12",
+		"python3 version 3.8":                  "3.8",
+		"zsh 5.9 (x86_64-pc-linux-gnu)":        "5.9",
+		`openjdk version "11.0.21" 2023-10-17`: "11.0.21",
+		"tmux 3":                               "3",
+	}
+	for output, expected := range testCases {
+		v, ok := findVersion(output)
+		if !ok {
+			t.Errorf("failed to find version in %q", output)
+		} else if v.String() != expected {
+			t.Errorf("expected version %s in %q, got %s", expected, output, v)
+		}
+	}
+
+	if _, ok := findVersion("no version here"); ok {
+		t.Error("found a version in output without one")
+	}
+}
+
+func TestVersionMatches_Constraints(t *testing.T) {
+	testCases := []struct {
+		version    string
+		constraint string
+		expected   bool
+	}{
+		{"2.34.1", ">= 2.30", true},
+		{"2.29.9", ">= 2.30", false},
+		{"2.30", ">= 2.30.0", true},
+		{"2.30.1", "> 2.30", true},
+		{"0.9.5", ">= 0.9, < 0.10", true},
+		{"0.10.0", ">= 0.9, < 0.10", false},
+		{"2.30.1", "2.30", true},
+		{"2.30.1", "= 2.30.0", false},
+		{"2.31", "!= 2.30", true},
+		{"1.2.9", "~1.2.3", true},
+		{"1.3.0", "~1.2.3", false},
+		{"1.9", "~1", true},
+		{"1.9.0", "^1.2", true},
+		{"2.0.0", "^1.2", false},
+		{"0.9.7", "^0.9", true},
+		{"0.10.0", "^0.9", false},
+	}
+	for _, test := range testCases {
+		v, _ := parseVersion(test.version)
+		constraints, err := parseVersionConstraints(test.constraint)
+		if err != nil {
+			t.Errorf("failed to parse constraint %q: %s", test.constraint, err)
+		} else if res := versionMatches(v, constraints); res != test.expected {
+			t.Errorf("expected %s matching %q to be %t", test.version, test.constraint, test.expected)
+		}
+	}
+}
+
+func TestParseVersionConstraints_RejectsInvalidConstraints(t *testing.T) {
+	for _, constraint := range []string{"", ">= foo", ">= 2.30,", "=> 2"} {
+		if _, err := parseVersionConstraints(constraint); err == nil {
+			t.Errorf("parsed invalid constraint %q", constraint)
+		}
+	}
+}
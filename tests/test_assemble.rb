@@ -0,0 +1,44 @@
+# frozen_string_literal: true
+
+require_relative './utils'
+
+RSpec.describe :assemble do
+  dotty = Dotty.new
+
+  it 'joins fragments in sorted order' do
+    dotty.in_config do
+      FileUtils.mkdir_p('conf.d')
+      File.write('conf.d/20-bar', "bar\n")
+      File.write('conf.d/10-foo', 'foo')
+    end
+
+    dotty_run_script '((:assemble {:src "conf.d/*" :dest "~/conf" :header "# header" :footer "# footer"}))', dotty do
+      dotty.in_home do
+        expect(File.read('conf')).to eq("# header\nfoo\nbar\n# footer\n")
+      end
+    end
+  end
+
+  it 'notes where fragments came from' do
+    dotty.in_config { File.write('foo', "foo\n") }
+
+    dotty_run_script '((:assemble {:src ("foo") :dest "~/conf" :comment "#"}))', dotty do
+      dotty.in_home do
+        expect(File.read('conf')).to eq("# fragment: foo\nfoo\n")
+      end
+    end
+  end
+
+  it 'only includes fragments whose conditions pass' do
+    dotty.in_config do
+      File.write('foo', "foo\n")
+      File.write('bar', "bar\n")
+    end
+
+    dotty_run_script '((:assemble ("foo" {:src "bar" :when "false"}) "~/conf"))', dotty do
+      dotty.in_home do
+        expect(File.read('conf')).to eq("foo\n")
+      end
+    end
+  end
+end
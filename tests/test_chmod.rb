@@ -0,0 +1,54 @@
+# frozen_string_literal: true
+
+require_relative './utils'
+
+RSpec.describe :chmod do
+  dotty = Dotty.new
+
+  it 'can change the permissions of a file' do
+    file = Pathname.new('foo')
+    dotty.in_home { file.open('w'); file.chmod(0o644) }
+
+    dotty_run_script '((:chmod {:path "~/foo" :mode 600}))', dotty do
+      dotty.in_home do
+        expect(file.stat.mode & 0o7777).to eq(0o600)
+      end
+    end
+  end
+
+  it 'can recursively change permissions' do
+    dir = Pathname.new('foo')
+    file = dir / 'bar'
+    dotty.in_home { dir.mkdir; file.open('w') }
+
+    dotty_run_script '((:chmod {:path "~/foo" :mode 600 :dir-mode 700 :recursive true}))', dotty do
+      dotty.in_home do
+        expect(dir.stat.mode & 0o7777).to eq(0o700)
+        expect(file.stat.mode & 0o7777).to eq(0o600)
+      end
+    end
+  end
+
+  it 'fixes the permissions of existing directories' do
+    dir = Pathname.new('foo')
+    dotty.in_home { dir.mkdir; dir.chmod(0o755) }
+
+    dotty_run_script '((:mkdir {:path "~/foo" :chmod 700}))', dotty do
+      dotty.in_home do
+        expect(dir.stat.mode & 0o7777).to eq(0o700)
+      end
+    end
+  end
+
+  it 'can set the permissions of linked files and their parents' do
+    src = Pathname.new('foo')
+    dotty.in_config { src.open('w'); src.chmod(0o644) }
+
+    dotty_run_script '((:link {:src "foo" :dest "~/.ssh/foo" :mode 600 :dir-mode 700}))', dotty do
+      dotty.in_home do
+        expect(Pathname.new('.ssh').stat.mode & 0o7777).to eq(0o700)
+        expect(Pathname.new('.ssh/foo').stat.mode & 0o7777).to eq(0o600)
+      end
+    end
+  end
+end
@@ -45,4 +45,48 @@ RSpec.describe :def do
   ensure
     dotty.cleanup
   end
+
+  it 'substitutes defaults for unset variables in :link' do
+    dotty.in_config { File.write('foo', 'foo') }
+    dotty_run_script '((:link "foo" "${dotty_unset_var:-~/bar}/foo"))', dotty do
+      dotty.in_home do
+        expect(Pathname.new('bar/foo')).to exist
+      end
+    end
+  end
+
+  it "doesn't link to paths with undefined variables" do
+    dotty.in_config { File.write('foo', 'foo') }
+    dotty.script '((:link "foo" "$dotty_unset_var/foo"))'
+    dotty.run_wait do |_, _, serr, proc|
+      err = serr.read
+      expect(proc.to_i).not_to eq(0), err
+      expect(err.uncolorize).to match(/undefined variable: dotty_unset_var/)
+      dotty.in_home do
+        expect(Pathname.new('foo')).not_to exist
+      end
+    end
+  ensure
+    dotty.cleanup
+  end
+
+  it 'can prepend to list variables' do
+    script = <<-EOF
+      ((:def (:prepend "PATH" "~/bin"))
+       (:shell {:cmd "echo path is $PATH" :stdout true}))
+    EOF
+    dotty_run_script script, dotty do |_, _, sout|
+      expect(sout.read.uncolorize).to match(/path is #{Regexp.escape(dotty.install_dir)}\/bin:/)
+    end
+  end
+
+  it 'can link into each directory in a list' do
+    dotty.in_config { File.write('foo', 'foo') }
+    dotty_run_script '((:def "dirs" ("~/bar" "~/baz")) (:link "foo" "${dirs[@]}/foo"))', dotty do
+      dotty.in_home do
+        expect(Pathname.new('bar/foo')).to exist
+        expect(Pathname.new('baz/foo')).to exist
+      end
+    end
+  end
 end
@@ -0,0 +1,42 @@
+# frozen_string_literal: true
+
+require 'colorize'
+require_relative './utils'
+
+RSpec.describe :diff do
+  dotty = Dotty.new
+
+  it 'shows how existing destinations differ from their sources' do
+    dotty.in_config { Pathname.new('foo').write("foo\nbar\n") }
+    dotty.in_home { Pathname.new('foo').write("foo\nbaz\n") }
+
+    dotty.script '((:link "foo" "~/foo"))'
+    dotty.run_subcommand('diff') do |_, sout, serr, thr|
+      out = sout.read
+      expect(thr.value.to_i).to eq(0), serr.read
+      expect(out).to include("--- #{File.join(dotty.install_dir, 'foo')}")
+      expect(out).to include("+++ #{File.join(dotty.config_dir, 'foo')}")
+      expect(out).to include("-baz\n+bar\n")
+    end
+
+    # diff never modifies the destination
+    dotty.in_home { expect(Pathname.new('foo').symlink?).to be(false) }
+  ensure
+    dotty.cleanup
+  end
+
+  it "doesn't show destinations that are already linked" do
+    src = Pathname.new('foo')
+    dotty.in_config { src.write("foo\n") }
+    dotty.in_home { Pathname.new('foo').make_symlink(Pathname.new(dotty.config_dir) / src) }
+
+    dotty.script '((:link "foo" "~/foo"))'
+    dotty.run_subcommand('diff') do |_, sout, serr, thr|
+      out = sout.read
+      expect(thr.value.to_i).to eq(0), serr.read
+      expect(out).to eq('')
+    end
+  ensure
+    dotty.cleanup
+  end
+end
@@ -0,0 +1,57 @@
+# frozen_string_literal: true
+
+require 'colorize'
+require_relative './utils'
+
+RSpec.describe :'env-file' do
+  dotty = Dotty.new
+
+  it 'loads variables from dotenv files' do
+    dotty.in_config do
+      File.write('.env', <<~ENV)
+        export EMAIL=me@example.com
+        GREETING="hello $EMAIL" # a comment
+      ENV
+    end
+    script = <<-EOF
+      ((:env-file ".env" {:path ".env.local" :optional true})
+       (:shell {:cmd "echo greeting is $GREETING" :stdout true}))
+    EOF
+    dotty_run_script script, dotty do |_, _, sout|
+      expect(sout.read.uncolorize).to match(/greeting is hello me@example.com/)
+    end
+  end
+
+  it 'fails when a file is missing' do
+    dotty.script '((:env-file ".env.missing"))'
+    dotty.run_wait do |_, _, serr, proc|
+      err = serr.read
+      expect(proc.to_i).not_to eq(0), err
+      expect(err.uncolorize).to match(/Failed to read environment file/)
+    end
+  ensure
+    dotty.cleanup
+  end
+
+  it 'fails when used in the environment file' do
+    dotty.in_config { File.write('.env', "EMAIL=me@example.com\n") }
+    dotty.env '((:env-file ".env"))'
+    dotty.script '()'
+    dotty.run_wait do |_, _, serr, proc|
+      err = serr.read
+      expect(proc.to_i).not_to eq(0), err
+      expect(err.uncolorize).to match(/can't be used in :def or your environment file/)
+    end
+  ensure
+    dotty.cleanup
+  end
+
+  it 'loads files given on the command line' do
+    dotty.in_config { File.write('.env', "EMAIL=me@example.com\n") }
+    env_file = File.join(dotty.config_dir, '.env')
+    script = '((:shell {:cmd "echo email is $EMAIL" :stdout true}))'
+    dotty_run_script script, dotty, '--env-file', env_file do |_, _, sout|
+      expect(sout.read.uncolorize).to match(/email is me@example.com/)
+    end
+  end
+end
@@ -0,0 +1,78 @@
+# frozen_string_literal: true
+
+require_relative './utils'
+
+RSpec.describe :extract do
+  dotty = Dotty.new
+
+  archive = lambda do |files|
+    dotty.in_config do
+      FileUtils.rm_rf('tool-1.0')
+      files.each do |path, content|
+        FileUtils.mkdir_p(File.dirname(File.join('tool-1.0', path)))
+        File.write(File.join('tool-1.0', path), content)
+      end
+      FileUtils.chmod(0o755, 'tool-1.0/bin/tool') if File.exist?('tool-1.0/bin/tool')
+      system('tar', '-czf', 'tool.tar.gz', 'tool-1.0', exception: true)
+    end
+  end
+
+  it 'extracts an archive' do
+    archive.call('README' => 'readme')
+    dotty_run_script '((:extract "tool.tar.gz" "~/tool"))', dotty do
+      dotty.in_home do
+        expect(File.read('tool/tool-1.0/README')).to eq('readme')
+      end
+    end
+  end
+
+  it 'strips leading components and links bins' do
+    archive.call('bin/tool' => "#!/bin/sh\n")
+    script = <<-EOF
+      ((:extract {:src "tool.tar.gz"
+                  :dest "~/tool"
+                  :strip-components 1
+                  :bin "bin/tool"
+                  :bin-dir "~/bin"}))
+    EOF
+    dotty_run_script script, dotty do
+      dotty.in_home do
+        expect(File.file?('tool/bin/tool')).to be(true)
+        expect(File.symlink?('bin/tool')).to be(true)
+        expect(File.readlink('bin/tool')).to eq(File.expand_path('tool/bin/tool'))
+      end
+    end
+  end
+
+  it 'only extracts again when the archive changes' do
+    script = '((:extract {:src "tool.tar.gz" :dest "~/tool" :strip-components 1}))'
+    archive.call('foo' => 'foo')
+    dotty_run_script script, dotty, cleanup: false
+    dotty.in_home { File.write('tool/bar', 'bar') }
+
+    dotty_run_script script, dotty, cleanup: false do
+      dotty.in_home { expect(File.exist?('tool/bar')).to be(true) }
+    end
+
+    archive.call('baz' => 'baz')
+    dotty_run_script script, dotty do
+      dotty.in_home do
+        expect(Dir.children('tool').sort).to eq(['.dotty-extract', 'baz'])
+      end
+    end
+  end
+
+  it "doesn't replace an existing directory" do
+    archive.call('foo' => 'foo')
+    dotty.in_home do
+      FileUtils.mkdir_p('tool')
+      File.write('tool/bar', 'bar')
+    end
+
+    dotty_run_script '((:extract {:src "tool.tar.gz" :dest "~/tool" :strip-components 1}))', dotty do
+      dotty.in_home do
+        expect(Dir.children('tool')).to eq(['bar'])
+      end
+    end
+  end
+end
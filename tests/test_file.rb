@@ -0,0 +1,56 @@
+# frozen_string_literal: true
+
+require_relative './utils'
+
+RSpec.describe :file do
+  dotty = Dotty.new
+
+  it 'writes content to a file' do
+    dotty_run_script '((:file "~/.hushlogin" "") (:file "~/foo/bar" ("foo" "bar")))', dotty do
+      dotty.in_home do
+        expect(File.read('.hushlogin')).to eq('')
+        expect(File.read('foo/bar')).to eq("foo\nbar\n")
+      end
+    end
+  end
+
+  it 'substitutes environment variables' do
+    ENV['file_var'] = 'baz'
+    dotty_run_script '((:file {:dest "~/foo" :content "${file_var}"}) (:file {:dest "~/bar" :content "$file_var" :expand false}))', dotty do
+      dotty.in_home do
+        expect(File.read('foo')).to eq('baz')
+        expect(File.read('bar')).to eq('$file_var')
+      end
+    end
+  ensure
+    ENV.delete('file_var')
+  end
+
+  it 'updates changed files and their permissions' do
+    dotty.in_home { File.write('foo', 'old'); File.chmod(0o644, 'foo') }
+
+    dotty_run_script '((:file {:dest "~/foo" :content "new" :mode 600}))', dotty do
+      dotty.in_home do
+        expect(File.read('foo')).to eq('new')
+        expect(File.stat('foo').mode & 0o7777).to eq(0o600)
+      end
+    end
+  end
+
+  it 'only replaces symlinks when forced' do
+    dotty.in_config { File.write('foo', 'foo') }
+    dotty.in_home { File.symlink(File.join(dotty.config_dir, 'foo'), 'foo') }
+
+    dotty_run_script '((:file "~/foo" "bar"))', dotty, cleanup: false do
+      dotty.in_home { expect(File.symlink?('foo')).to be(true) }
+      dotty.in_config { expect(File.read('foo')).to eq('foo') }
+    end
+
+    dotty_run_script '((:file {:dest "~/foo" :content "bar" :force true}))', dotty do
+      dotty.in_home do
+        expect(File.symlink?('foo')).to be(false)
+        expect(File.read('foo')).to eq('bar')
+      end
+    end
+  end
+end
@@ -0,0 +1,57 @@
+# frozen_string_literal: true
+
+require_relative './utils'
+
+RSpec.describe :git do
+  dotty = Dotty.new
+  git_env = {
+    'GIT_AUTHOR_NAME' => 'dotty', 'GIT_AUTHOR_EMAIL' => 'dotty@example.com',
+    'GIT_COMMITTER_NAME' => 'dotty', 'GIT_COMMITTER_EMAIL' => 'dotty@example.com'
+  }
+
+  commit = lambda do |content, tag = nil|
+    dotty.in_config do
+      Dir.chdir('repo') do
+        File.write('foo', content)
+        system(git_env, 'git', 'add', 'foo', exception: true)
+        system(git_env, 'git', 'commit', '--quiet', '-m', content, exception: true)
+        system(git_env, 'git', 'tag', tag, exception: true) unless tag.nil?
+      end
+    end
+  end
+
+  before do
+    dotty.in_config { system('git', 'init', '--quiet', 'repo', exception: true) }
+    commit.call('one', 'v1')
+    commit.call('two')
+  end
+
+  it 'clones a repository' do
+    dotty_run_script '((:git "repo" "~/repo"))', dotty do
+      dotty.in_home do
+        expect(File.read('repo/foo')).to eq('two')
+      end
+    end
+  end
+
+  it 'checks out a ref' do
+    dotty_run_script '((:git {:repo "repo" :dest "~/repo" :ref "v1"}))', dotty do
+      dotty.in_home do
+        expect(File.read('repo/foo')).to eq('one')
+      end
+    end
+  end
+
+  it 'only fetches changes when updating' do
+    dotty_run_script '((:git "repo" "~/repo"))', dotty, cleanup: false
+    commit.call('three')
+
+    dotty_run_script '((:git "repo" "~/repo"))', dotty, cleanup: false do
+      dotty.in_home { expect(File.read('repo/foo')).to eq('two') }
+    end
+
+    dotty_run_script '((:git {:repo "repo" :dest "~/repo" :update true}))', dotty do
+      dotty.in_home { expect(File.read('repo/foo')).to eq('three') }
+    end
+  end
+end
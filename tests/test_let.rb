@@ -0,0 +1,36 @@
+# frozen_string_literal: true
+
+require 'colorize'
+require_relative './utils'
+
+RSpec.describe :let do
+  dotty = Dotty.new
+
+  it 'only defines variables for its body' do
+    script = <<-EOF
+      ((:def "foo" "outer")
+       (:let ("foo" "inner")
+         (:shell {:cmd "echo in let foo is $foo" :stdout true}))
+       (:shell {:cmd "echo after let foo is $foo" :stdout true}))
+    EOF
+    dotty_run_script script, dotty do |_, _, sout|
+      out = sout.read.uncolorize
+      expect(out).to match(/in let foo is inner/)
+      expect(out).to match(/after let foo is outer/)
+    end
+  end
+
+  it 'only overrides options for its body' do
+    script = <<-EOF
+      ((:let ((:mkdir :chmod "0700"))
+         (:mkdir "~/foo"))
+       (:mkdir "~/bar"))
+    EOF
+    dotty_run_script script, dotty do
+      dotty.in_home do
+        expect(File.stat('foo').mode & 0o777).to eq(0o700)
+        expect(File.stat('bar').mode & 0o777).to eq(0o744)
+      end
+    end
+  end
+end
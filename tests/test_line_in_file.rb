@@ -0,0 +1,65 @@
+# frozen_string_literal: true
+
+require_relative './utils'
+
+RSpec.describe :'line-in-file' do
+  dotty = Dotty.new
+
+  it 'appends a missing line only once' do
+    dotty.in_home { File.write('.bashrc', "export FOO=1\n") }
+
+    [false, true].each do |cleanup|
+      dotty_run_script '((:line-in-file "~/.bashrc" "source ~/.dotty"))', dotty, cleanup: cleanup do
+        dotty.in_home do
+          expect(File.read('.bashrc')).to eq("export FOO=1\nsource ~/.dotty\n")
+        end
+      end
+    end
+  end
+
+  it 'replaces lines matching a regexp' do
+    dotty.in_home { File.write('.bashrc', "export FOO=1\nexport BAR=1\n") }
+
+    dotty_run_script '((:line-in-file {:path "~/.bashrc" :regexp "^export FOO=" :line "export FOO=2"}))', dotty do
+      dotty.in_home do
+        expect(File.read('.bashrc')).to eq("export FOO=2\nexport BAR=1\n")
+      end
+    end
+  end
+
+  it 'removes absent lines' do
+    dotty.in_home { File.write('.bashrc', "# foo\nbar\n") }
+
+    dotty_run_script '((:line-in-file {:path "~/.bashrc" :regexp "^#" :state "absent"}))', dotty do
+      dotty.in_home do
+        expect(File.read('.bashrc')).to eq("bar\n")
+      end
+    end
+  end
+end
+
+RSpec.describe :'block-in-file' do
+  dotty = Dotty.new
+
+  it 'keeps a marked block up to date' do
+    dotty.in_home { File.write('.bashrc', "foo\n") }
+
+    dotty_run_script '((:block-in-file "~/.bashrc" ("bar" "baz")))', dotty, cleanup: false do
+      dotty.in_home do
+        expect(File.read('.bashrc')).to eq("foo\n# BEGIN dotty\nbar\nbaz\n# END dotty\n")
+      end
+    end
+
+    dotty_run_script '((:block-in-file "~/.bashrc" "bag"))', dotty, cleanup: false do
+      dotty.in_home do
+        expect(File.read('.bashrc')).to eq("foo\n# BEGIN dotty\nbag\n# END dotty\n")
+      end
+    end
+
+    dotty_run_script '((:block-in-file {:path "~/.bashrc" :state "absent"}))', dotty do
+      dotty.in_home do
+        expect(File.read('.bashrc')).to eq("foo\n")
+      end
+    end
+  end
+end
@@ -66,6 +66,37 @@ RSpec.describe :link do
     end
   end
 
+  it 'can glob recursively for sources' do
+    srcs = %w[bin/foo bin/bar/baz bin/bar/bag.md].map(&Pathname.method(:new))
+    dotty.in_config do
+      srcs.each { |src| src.dirname.mkpath; src.open('w') }
+    end
+
+    dotty_run_script '((:link {:src "bin/**" :dest "~/bin" :glob true :exclude "*.md" :preserve-structure true}))', dotty do
+      dotty.in_home do
+        %w[bin/foo bin/bar/baz].map(&Pathname.method(:new)).each do |dst|
+          expect(dst.symlink?).to be(true), "#{dst} is not a symlink"
+          expect(dst.readlink).to eq(Pathname.new('') / dotty.config_dir / dst)
+        end
+        expect(Pathname.new('bin/bar/bag.md')).not_to exist
+      end
+    end
+  end
+
+  it 'skips globbed sources listed in .dottyignore' do
+    dotty.in_config do
+      %w[foo bar].each { |src| Pathname.new(src).open('w') }
+      Pathname.new('.dottyignore').write("bar\n")
+    end
+
+    dotty_run_script '((:link {:src "*" :dest "~/dst" :glob true}))', dotty do
+      dotty.in_home do
+        expect(Pathname.new('dst/foo')).to exist
+        expect(Pathname.new('dst/bar')).not_to exist
+      end
+    end
+  end
+
   it 'can link into a dir' do
     # destinations with a trailing slash point into directories
     src = Pathname.new('foo')
@@ -321,4 +352,117 @@ RSpec.describe :link do
       end
     end
   end
+
+  context 'adopt is true' do
+    it 'moves the destination into the source' do
+      src = Pathname.new('foo')
+      dst = Pathname.new('bar')
+      msg = rand_str
+      dotty.in_home do
+        dst.write(msg)
+        expect(dst).to exist
+      end
+
+      dotty_run_script '((:link {:src "foo" :dest "~/bar" :adopt true}))', dotty do
+        dotty.in_config do
+          expect(src).to exist
+          expect(src.read).to eq(msg)
+        end
+        dotty.in_home do
+          expect(dst.symlink?).to be(true), "#{dst} is not a symlink"
+          expect(dst.readlink).to eq(Pathname.new('') / dotty.config_dir / src)
+        end
+      end
+    end
+
+    it 'can be enabled from the command line' do
+      src = Pathname.new('foo')
+      dst = Pathname.new('bar')
+      dotty.in_home { dst.open('w') }
+
+      dotty_run_script '((:link "foo" "~/bar"))', dotty, '--adopt' do
+        dotty.in_config { expect(src).to exist }
+        dotty.in_home do
+          expect(dst.symlink?).to be(true), "#{dst} is not a symlink"
+        end
+      end
+    end
+  end
+
+  context 'links conflict' do
+    it 'reports conflicting destinations and keeps the first' do
+      dotty.in_config do
+        %w[foo bar].each { |src| Pathname.new(src).open('w') }
+      end
+
+      dotty.script '((:link "foo" "~/baz") (:link "bar" "~/baz"))'
+      dotty.run_wait do |_, _, serr, proc|
+        err = serr.read
+        expect(proc.to_i).not_to eq(0), err
+        expect(err.uncolorize).to match(/ERR Multiple links have the same destination/)
+        dotty.in_home do
+          expect(Pathname.new('baz').readlink).to eq(Pathname.new('') / dotty.config_dir / 'foo')
+        end
+      end
+    ensure
+      dotty.cleanup
+    end
+
+    it 'can let later links override earlier ones' do
+      dotty.in_config do
+        %w[foo bar].each { |src| Pathname.new(src).open('w') }
+      end
+
+      dotty_run_script '((:link "foo" "~/baz") (:link "bar" "~/baz"))', dotty, '--override-links' do |_, _, _, serr|
+        expect(serr.read.uncolorize).to match(/WRN Multiple links have the same destination/)
+        dotty.in_home do
+          expect(Pathname.new('baz').readlink).to eq(Pathname.new('') / dotty.config_dir / 'bar')
+        end
+      end
+    end
+  end
+
+  context 'interactive is true' do
+    it 'can backup and overwrite existing destinations' do
+      src = Pathname.new('foo')
+      dst = Pathname.new('bar')
+      msg = rand_str
+      dotty.in_config { src.open('w') }
+      dotty.in_home { dst.write(msg) }
+
+      dotty.script '((:link "foo" "~/bar"))'
+      dotty.run('--interactive') do |sin, _sout, serr, thr|
+        sin.puts 'b'
+        sin.close
+        err = serr.read
+        expect(thr.value.to_i).to eq(0), err
+      end
+
+      dotty.in_home do
+        expect(dst.symlink?).to be(true), "#{dst} is not a symlink"
+        expect(Pathname.new('bar.dotty-backup').read).to eq(msg)
+      end
+    ensure
+      dotty.cleanup
+    end
+
+    it 'skips existing destinations by default' do
+      src = Pathname.new('foo')
+      dst = Pathname.new('bar')
+      dotty.in_config { src.open('w') }
+      dotty.in_home { dst.open('w') }
+
+      dotty.script '((:link "foo" "~/bar"))'
+      dotty.run('--interactive') do |sin, _sout, serr, thr|
+        sin.close
+        err = serr.read
+        expect(thr.value.to_i).to eq(0), err
+        expect(err.uncolorize).to match(/INF Skipping linking src to dest because dest exists/)
+      end
+
+      dotty.in_home { expect(dst.symlink?).to be(false) }
+    ensure
+      dotty.cleanup
+    end
+  end
 end
@@ -0,0 +1,42 @@
+# frozen_string_literal: true
+
+require 'json'
+require_relative './utils'
+
+RSpec.describe :merge do
+  dotty = Dotty.new
+
+  it 'merges keys into an existing file' do
+    dotty.in_config { File.write('settings.json', '{"a": {"b": 2}, "c": [2]}') }
+    dotty.in_home { File.write('settings.json', '{"a": {"b": 1, "d": 1}, "c": [1], "e": 1}') }
+
+    dotty_run_script '((:merge {:src "settings.json" :dest "~/settings.json" :arrays "append"}))', dotty do
+      dotty.in_home do
+        expect(JSON.parse(File.read('settings.json')))
+          .to eq({ 'a' => { 'b' => 2, 'd' => 1 }, 'c' => [1, 2], 'e' => 1 })
+      end
+    end
+  end
+
+  it 'removes keys' do
+    dotty.in_config { File.write('settings.json', '{}') }
+    dotty.in_home { File.write('settings.json', '{"a.b": 1, "c": {"d": 1, "e": 1}}') }
+
+    dotty_run_script '((:merge {:src "settings.json" :dest "~/settings.json" :remove ("a.b" ("c" "d"))}))', dotty do
+      dotty.in_home do
+        expect(JSON.parse(File.read('settings.json'))).to eq({ 'c' => { 'e' => 1 } })
+      end
+    end
+  end
+
+  it 'merges between formats' do
+    dotty.in_config { File.write('settings.yml', "a: 1\n") }
+    dotty.in_home { File.write('settings.ini', "[b]\nc = 2\n") }
+
+    dotty_run_script '((:merge "settings.yml" "~/settings.ini"))', dotty do
+      dotty.in_home do
+        expect(File.read('settings.ini')).to eq("a = 1\n\n[b]\nc = 2\n")
+      end
+    end
+  end
+end
@@ -80,4 +80,16 @@ RSpec.describe :mkdir do
   ensure
     ENV.delete('path_var')
   end
+
+  it 'shows privileged directories when inspecting' do
+    dotty = Dotty.new
+    dotty.script '((:mkdir {:path "/etc/foo" :sudo true}))'
+    dotty.run_subcommand('inspect') do |_, sout, serr, thr|
+      out = sout.read
+      expect(thr.value.to_i).to eq(0), serr.read
+      expect(out).to match(%r{^sudo mkdir \d+ /etc/foo$})
+    end
+  ensure
+    dotty.cleanup
+  end
 end
@@ -0,0 +1,40 @@
+# frozen_string_literal: true
+
+require_relative './utils'
+
+RSpec.describe :patch do
+  dotty = Dotty.new
+  patch = <<~PATCH
+    --- a/foo
+    +++ b/foo
+    @@ -1,3 +1,3 @@
+     a
+    -b
+    +B
+     c
+  PATCH
+
+  it 'applies a patch only once' do
+    dotty.in_config { File.write('foo.patch', patch) }
+    dotty.in_home { File.write('foo', "a\nb\nc\n") }
+
+    [false, true].each do |cleanup|
+      dotty_run_script '((:patch "~/foo" "foo.patch"))', dotty, cleanup: cleanup do
+        dotty.in_home do
+          expect(File.read('foo')).to eq("a\nB\nc\n")
+        end
+      end
+    end
+  end
+
+  it 'leaves the file unchanged when a hunk is rejected' do
+    dotty.in_config { File.write('foo.patch', patch) }
+    dotty.in_home { File.write('foo', "a\nx\nc\n") }
+
+    dotty_run_script '((:patch {:file "~/foo" :diff "foo.patch"}))', dotty do
+      dotty.in_home do
+        expect(File.read('foo')).to eq("a\nx\nc\n")
+      end
+    end
+  end
+end
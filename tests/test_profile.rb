@@ -0,0 +1,48 @@
+# frozen_string_literal: true
+
+require 'colorize'
+require 'socket'
+require_relative './utils'
+
+RSpec.describe :profile do
+  dotty = Dotty.new
+
+  before do
+    dotty.env <<-EOF
+      ((:profile "work" {:hostname "#{Socket.gethostname}"
+                         :bots "vpn"
+                         :def ("EMAIL" "me@work.com")})
+       (:profile "home" {:hostname "not-#{Socket.gethostname}"}))
+    EOF
+  end
+
+  it 'activates profiles matching the hostname' do
+    script = <<-EOF
+      ((:when (:profile "work") (:debug "work profile"))
+       (:when (:profile "home") (:debug "home profile"))
+       (:when (:bot "vpn") (:debug "vpn bot"))
+       (:shell {:cmd "echo $EMAIL" :stdout true}))
+    EOF
+
+    dotty_run_script script, dotty do |_, _, sout, serr|
+      serr = serr.read.uncolorize
+      expect(serr).to match(/DBG work profile/)
+      expect(serr).not_to match(/DBG home profile/)
+      expect(serr).to match(/DBG vpn bot/)
+      expect(sout.read).to match(/me@work.com/)
+    end
+  end
+
+  it 'only activates profiles given on the command line' do
+    script = <<-EOF
+      ((:when (:profile "work") (:debug "work profile"))
+       (:when (:profile "home") (:debug "home profile")))
+    EOF
+
+    dotty_run_script script, dotty, '--profile', 'home' do |_, _, _, serr|
+      serr = serr.read.uncolorize
+      expect(serr).not_to match(/DBG work profile/)
+      expect(serr).to match(/DBG home profile/)
+    end
+  end
+end
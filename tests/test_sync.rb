@@ -0,0 +1,83 @@
+# frozen_string_literal: true
+
+require_relative './utils'
+
+RSpec.describe :sync do
+  dotty = Dotty.new
+
+  it 'copies a directory to dest' do
+    dotty.in_config do
+      FileUtils.mkdir_p('foo/bar')
+      File.write('foo/baz', 'baz')
+      File.write('foo/bar/bag', 'bag')
+    end
+
+    dotty_run_script '((:sync "foo" "~/foo"))', dotty do
+      dotty.in_home do
+        expect(File.read('foo/baz')).to eq('baz')
+        expect(File.read('foo/bar/bag')).to eq('bag')
+        expect(File.symlink?('foo/baz')).to be(false)
+      end
+    end
+  end
+
+  it 'updates files whose contents have changed' do
+    dotty.in_config { FileUtils.mkdir_p('foo'); File.write('foo/bar', 'new') }
+    dotty.in_home { FileUtils.mkdir_p('foo'); File.write('foo/bar', 'old') }
+
+    dotty_run_script '((:sync {:src "foo" :dest "~/foo" :checksum true}))', dotty do
+      dotty.in_home do
+        expect(File.read('foo/bar')).to eq('new')
+      end
+    end
+  end
+
+  it 'only deletes extra files when asked to' do
+    dotty.in_config { FileUtils.mkdir_p('foo'); File.write('foo/bar', 'bar') }
+    dotty.in_home { FileUtils.mkdir_p('foo'); File.write('foo/baz', 'baz') }
+
+    dotty_run_script '((:sync "foo" "~/foo"))', dotty, cleanup: false do
+      dotty.in_home do
+        expect(File.exist?('foo/baz')).to be(true)
+      end
+    end
+
+    dotty_run_script '((:sync {:src "foo" :dest "~/foo" :delete true}))', dotty do
+      dotty.in_home do
+        expect(File.exist?('foo/bar')).to be(true)
+        expect(File.exist?('foo/baz')).to be(false)
+      end
+    end
+  end
+
+  it 'leaves excluded files alone' do
+    dotty.in_config do
+      FileUtils.mkdir_p('foo')
+      File.write('foo/bar', 'bar')
+      File.write('foo/bar.bak', 'bak')
+    end
+    dotty.in_home { FileUtils.mkdir_p('foo'); File.write('foo/baz.bak', 'baz') }
+
+    dotty_run_script '((:sync {:src "foo" :dest "~/foo" :delete true :exclude "*.bak"}))', dotty do
+      dotty.in_home do
+        expect(File.exist?('foo/bar')).to be(true)
+        expect(File.exist?('foo/bar.bak')).to be(false)
+        expect(File.exist?('foo/baz.bak')).to be(true)
+      end
+    end
+  end
+
+  it 'lists changes without making them when inspected' do
+    dotty.in_config { FileUtils.mkdir_p('foo'); File.write('foo/bar', 'bar') }
+    dotty.script '((:sync "foo" "~/foo"))'
+
+    dotty.run_subcommand('inspect') do |_, sout, serr, thr|
+      out = sout.read
+      expect(thr.value.to_i).to eq(0), serr.read
+      expect(out).to match(/^  copy bar$/)
+    end
+    dotty.in_home { expect(File.exist?('foo/bar')).to be(false) }
+  ensure
+    dotty.cleanup
+  end
+end
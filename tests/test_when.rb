@@ -91,4 +91,61 @@ RSpec.describe :when do
       end
     end
   end
+
+  it 'supports native path predicates' do
+    msg = rand_str
+    dotty = Dotty.new
+    dotty.in_config { File.write('foo', '') }
+    dotty_run_script "((:when (:and (:file \"foo\") (:not (:exists \"bar\"))) (:debug #{msg.inspect}) ))", dotty, cleanup: false do |_, _, _, serr|
+      expect(serr.read.uncolorize).to match(/DBG #{msg}/)
+    end
+    dotty_run_script "((:when (:dir \"foo\") (:debug #{msg.inspect}) ))", dotty do |_, _, _, serr|
+      expect(serr.read.uncolorize).not_to match(/DBG #{msg}/)
+    end
+  end
+
+  it 'supports native environment predicates' do
+    msg = rand_str
+    dotty_run_script "((:def \"FOO\" \"bar\") (:when (:env= \"FOO\" \"bar\") (:debug #{msg.inspect}) ))" do |_, _, _, serr|
+      expect(serr.read.uncolorize).to match(/DBG #{msg}/)
+    end
+    dotty_run_script "((:when (:executable \"#{rand_str}\") (:debug #{msg.inspect}) ))" do |_, _, _, serr|
+      expect(serr.read.uncolorize).not_to match(/DBG #{msg}/)
+    end
+  end
+
+  it 'supports comparing program versions' do
+    msg = rand_str
+    dotty_run_script "((:when (:version \"ruby\" \">= 2\") (:debug #{msg.inspect}) ))" do |_, _, _, serr|
+      expect(serr.read.uncolorize).to match(/DBG #{msg}/)
+    end
+    dotty_run_script "((:when (:version \"ruby\" \"< 1\") (:debug #{msg.inspect}) ))" do |_, _, _, serr|
+      expect(serr.read.uncolorize).not_to match(/DBG #{msg}/)
+    end
+  end
+
+  it 'runs the else branch of an if when the condition fails' do
+    then_msg, else_msg = rand_str, rand_str
+    dotty_run_script "((:if \"false\" (:debug #{then_msg.inspect}) :else (:debug #{else_msg.inspect})))" do |_, _, _, serr|
+      serr = serr.read.uncolorize
+      expect(serr).not_to match(/DBG #{then_msg}/)
+      expect(serr).to match(/DBG #{else_msg}/)
+    end
+  end
+
+  it 'runs only the first passing branch of a cond' do
+    msgs = [rand_str, rand_str, rand_str]
+    script = <<-EOF
+      ((:cond "false" (:debug #{msgs[0].inspect})
+              "true" ((:debug #{msgs[1].inspect}))
+              :else (:debug #{msgs[2].inspect})))
+    EOF
+
+    dotty_run_script script do |_, _, _, serr|
+      serr = serr.read.uncolorize
+      expect(serr).not_to match(/DBG #{msgs[0]}/)
+      expect(serr).to match(/DBG #{msgs[1]}/)
+      expect(serr).not_to match(/DBG #{msgs[2]}/)
+    end
+  end
 end
@@ -21,10 +21,14 @@ class Dotty
   end
 
   def run(*flags, &block)
+    run_subcommand('install', *flags, &block)
+  end
+
+  def run_subcommand(cmd, *flags, &block)
     Open3.popen3(dotty_bin,
                  '--log-level', 'debug',
                  # "--log-json",
-                 'install',
+                 cmd,
                  '--home', install_dir,
                  '--cd', config_dir,
                  *flags, &block)
@@ -90,8 +94,9 @@ module DottyHelpers
   # utitlity method to create a new dotty instance, assign
   # a script, run the script, and then run dotty on it. If
   # dotty exits sucesffully, it then passes the inputs of
-  # :dotty_run_script: to block.
-  def dotty_run_script(script, dotty = nil, *flags, &block)
+  # :dotty_run_script: to block. Pass cleanup: false to keep
+  # the config and install directories for a later run.
+  def dotty_run_script(script, dotty = nil, *flags, cleanup: true, &block)
     dotty = Dotty.new if dotty.nil?
     dotty.script script
     dotty.run_wait(*flags) do |sin, sout, serr, proc|
@@ -100,7 +105,7 @@ module DottyHelpers
       block&.call(dotty, sin, sout, StringIO.new(err), proc)
     end
   ensure
-    dotty.cleanup
+    dotty.cleanup if cleanup
   end
 end
 